@@ -51,10 +51,10 @@ func (p Agent) Diff(agent Agent) map[string]interface{} {
 	if strings.Compare(p.BuildState, agent.BuildState) != 0 {
 		result["build_state"] = agent.BuildState
 	}
-	if reflect.DeepEqual(p.Resources, agent.Resources) {
+	if !reflect.DeepEqual(p.Resources, agent.Resources) {
 		result["resources"] = agent.Resources
 	}
-	if reflect.DeepEqual(p.Environments, agent.Environments) {
+	if !reflect.DeepEqual(p.Environments, agent.Environments) {
 		result["environments"] = agent.Environments
 	}
 	return result
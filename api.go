@@ -1,27 +1,64 @@
 package gocd
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const VERSION = "0.1.0"
 
+// Client is safe for concurrent use by multiple goroutines.
 type Client struct {
-	host     string
-	login    string
-	password string
-	Etag     string
-	EtagEnv  string
+	host       string
+	auth       Authenticator
+	etags      etagStore
+	httpClient *http.Client
+	transport  http.RoundTripper
+	tlsConfig  *tls.Config
+	proxy      *url.URL
+	timeout    time.Duration
+	userAgent  string
+	retry      RetryPolicy
+	updates    int
+	server     serverInfo
+
+	logger        Logger
+	beforeRequest []BeforeRequestHook
+	afterResponse []AfterResponseHook
+	limiter       *rateLimiter
+	inFlight      chan struct{}
+	verifyUploads bool
 }
 
-func New(host, login, password string) *Client {
-	return &Client{host: host, login: login, password: password}
+func New(host, login, password string, opts ...Option) *Client {
+	client := &Client{host: host, auth: Anonymous{},
+		userAgent: fmt.Sprintf("go-gocd/%s", VERSION),
+		updates:   DefaultUpdateAttempts}
+	if login != "" || password != "" {
+		client.auth = BasicAuth{Login: login, Password: password}
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	client.httpClient = client.buildHTTPClient()
+	return client
 }
 
 func (p *Client) unmarshal(data io.ReadCloser, v interface{}) error {
@@ -29,32 +66,104 @@ func (p *Client) unmarshal(data io.ReadCloser, v interface{}) error {
 	if body, err := ioutil.ReadAll(data); err != nil {
 		return err
 	} else {
-
-		//fmt.Println(string(body))
-
 		return json.Unmarshal(body, v)
 	}
 }
 
 func (p *Client) createError(resp *http.Response) error {
 	defer resp.Body.Close()
-	if body, err := ioutil.ReadAll(resp.Body); err == nil {
-		return fmt.Errorf("Operation error: %s (%s)", resp.Status, body)
+	body, _ := ioutil.ReadAll(resp.Body)
+	return newAPIError(resp, body)
+}
+
+func (p *Client) goCDRequest(ctx context.Context, method string, resource string, body []byte, headers map[string]string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := p.goCDRequestOnce(ctx, method, resource, body, headers, attempt+1)
+		if attempt+1 >= p.retry.attempts() || ctx.Err() != nil {
+			return resp, err
+		}
+
+		var delay time.Duration
+		switch true {
+		case err != nil && (idempotent(method) && transportError(err) || connectionError(err)):
+			delay = p.retry.backoff(attempt)
+		case err == nil && idempotent(method) && p.retry.retryStatus(resp.StatusCode):
+			var ok bool
+			if delay, ok = retryAfter(resp); ok {
+				delay = p.retry.clamp(delay)
+			} else {
+				delay = p.retry.backoff(attempt)
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		default:
+			return resp, err
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Client) goCDRequestOnce(ctx context.Context, method string, resource string, body []byte, headers map[string]string, attempt int) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, resource, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
 	}
-	return fmt.Errorf("Operation error: %s", resp.Status)
+	req.Header.Set("User-Agent", p.userAgent)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return p.send(ctx, req, body, attempt)
 }
 
-func (p *Client) goCDRequest(method string, resource string, body []byte, headers map[string]string) (*http.Response, error) {
-	req, _ := http.NewRequest(method, resource, bytes.NewReader(body))
+// goCDStream sends a single request whose body is read from r, so it is
+// never retried. size is the length of the body, or -1 when unknown.
+func (p *Client) goCDStream(ctx context.Context, method string, resource string, r io.Reader, size int64, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, resource, r)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("User-Agent", p.userAgent)
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
-	req.SetBasicAuth(p.login, p.password)
-	return http.DefaultClient.Do(req)
+	return p.send(ctx, req, nil, 1)
+}
+
+func (p *Client) send(ctx context.Context, req *http.Request, body []byte, attempt int) (*http.Response, error) {
+	if err := p.auth.Authenticate(req); err != nil {
+		return nil, err
+	}
+
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var resp *http.Response
+	if !p.hooked() {
+		resp, err = p.httpClient.Do(req)
+	} else {
+		start := time.Now()
+		hookCtx, event := p.runBeforeRequest(ctx, req, body, attempt)
+		resp, err = p.httpClient.Do(req.WithContext(hookCtx))
+		p.runAfterResponse(hookCtx, event, resp, err, time.Since(start))
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return withRelease(resp, release), nil
 }
 
 func (p *Client) Version() (*Version, error) {
-	resp, err := p.goCDRequest("GET",
+	return p.VersionContext(context.Background())
+}
+
+func (p *Client) VersionContext(ctx context.Context) (*Version, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
 		fmt.Sprintf("%s/go/api/version", p.host),
 		[]byte{},
 		map[string]string{"Accept": "application/vnd.go.cd.v1+json"})
@@ -69,7 +178,11 @@ func (p *Client) Version() (*Version, error) {
 }
 
 func (p *Client) GetPipelineInstance(name string, inst int) (*PipelineInstance, error) {
-	resp, err := p.goCDRequest("GET",
+	return p.GetPipelineInstanceContext(context.Background(), name, inst)
+}
+
+func (p *Client) GetPipelineInstanceContext(ctx context.Context, name string, inst int) (*PipelineInstance, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
 		fmt.Sprintf("%s/go/api/pipelines/%s/instance/%d", p.host, name, inst),
 		[]byte{},
 		map[string]string{})
@@ -84,7 +197,11 @@ func (p *Client) GetPipelineInstance(name string, inst int) (*PipelineInstance,
 }
 
 func (p *Client) GetHistoryPipelineInstance(name string) ([]*PipelineInstance, error) {
-	resp, err := p.goCDRequest("GET",
+	return p.GetHistoryPipelineInstanceContext(context.Background(), name)
+}
+
+func (p *Client) GetHistoryPipelineInstanceContext(ctx context.Context, name string) ([]*PipelineInstance, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
 		fmt.Sprintf("%s/go/api/pipelines/%s/history", p.host, name),
 		[]byte{},
 		map[string]string{})
@@ -104,27 +221,39 @@ func (p *Client) GetHistoryPipelineInstance(name string) ([]*PipelineInstance, e
 }
 
 func (p *Client) GetPipelineConfig(name string) (*PipelineConfig, error) {
-	resp, err := p.goCDRequest("GET",
+	return p.GetPipelineConfigContext(context.Background(), name)
+}
+
+func (p *Client) GetPipelineConfigContext(ctx context.Context, name string) (*PipelineConfig, error) {
+	accept, err := p.accept(ctx, apiPipelineConfig)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
 		fmt.Sprintf("%s/go/api/admin/pipelines/%s", p.host, name),
 		[]byte{},
-		map[string]string{"Accept": "application/vnd.go.cd.v2+json"})
+		map[string]string{"Accept": accept})
 
 	switch true {
 	case err != nil:
 		return nil, err
 	case resp.StatusCode != http.StatusOK:
 		return nil, p.createError(resp)
-	default:
-		if tag := resp.Header["Etag"]; len(tag) > 0 {
-			p.Etag = tag[0]
-		}
 	}
 
 	pipeline := NewPipelineConfig()
-	return pipeline, p.unmarshal(resp.Body, pipeline)
+	if err := p.unmarshal(resp.Body, pipeline); err != nil {
+		return nil, err
+	}
+	pipeline.Etag = p.etags.update(etagPipeline, name, resp)
+	return pipeline, nil
 }
 
 func (p *Client) NewPipelineConfig(pipeline *PipelineConfig, group string) error {
+	return p.NewPipelineConfigContext(context.Background(), pipeline, group)
+}
+
+func (p *Client) NewPipelineConfigContext(ctx context.Context, pipeline *PipelineConfig, group string) error {
 	data := struct {
 		Group    string         `json:"group"`
 		Pipeline PipelineConfig `json:"pipeline"`
@@ -135,11 +264,15 @@ func (p *Client) NewPipelineConfig(pipeline *PipelineConfig, group string) error
 		return err
 	}
 
-	resp, err := p.goCDRequest("POST",
+	accept, err := p.accept(ctx, apiPipelineConfig)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
 		fmt.Sprintf("%s/go/api/admin/pipelines", p.host),
 		body,
 		map[string]string{"Content-Type": "application/json",
-			"Accept": "application/vnd.go.cd.v2+json"})
+			"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -147,16 +280,25 @@ func (p *Client) NewPipelineConfig(pipeline *PipelineConfig, group string) error
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
 		return nil
 	}
 }
 
 func (p *Client) NewPipelineConfigRaw(data []byte) error {
-	resp, err := p.goCDRequest("POST",
+	return p.NewPipelineConfigRawContext(context.Background(), data)
+}
+
+func (p *Client) NewPipelineConfigRawContext(ctx context.Context, data []byte) error {
+	accept, err := p.accept(ctx, apiPipelineConfig)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
 		fmt.Sprintf("%s/go/api/admin/pipelines", p.host),
 		data,
 		map[string]string{"Content-Type": "application/json",
-			"Accept": "application/vnd.go.cd.v2+json"})
+			"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -164,21 +306,30 @@ func (p *Client) NewPipelineConfigRaw(data []byte) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
 		return nil
 	}
 }
 
 func (p *Client) SetPipelineConfig(pipeline *PipelineConfig) error {
+	return p.SetPipelineConfigContext(context.Background(), pipeline)
+}
+
+func (p *Client) SetPipelineConfigContext(ctx context.Context, pipeline *PipelineConfig) error {
 	body, err := json.Marshal(pipeline)
 	if err != nil {
 		return err
 	}
-	resp, err := p.goCDRequest("PUT",
+	accept, err := p.accept(ctx, apiPipelineConfig)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "PUT",
 		fmt.Sprintf("%s/go/api/admin/pipelines/%s", p.host, pipeline.Name),
 		body,
-		map[string]string{"If-Match": p.Etag,
+		map[string]string{"If-Match": p.etags.ifMatch(etagPipeline, pipeline.Name, pipeline.Etag),
 			"Content-Type": "application/json",
-			"Accept":       "application/vnd.go.cd.v2+json"})
+			"Accept":       accept})
 
 	switch true {
 	case err != nil:
@@ -186,20 +337,27 @@ func (p *Client) SetPipelineConfig(pipeline *PipelineConfig) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
-		if tag := resp.Header["Etag"]; len(tag) > 0 {
-			p.Etag = tag[0]
-		}
+		resp.Body.Close()
+		pipeline.Etag = p.etags.update(etagPipeline, pipeline.Name, resp)
 		return nil
 	}
 }
 
 func (p *Client) SetPipelineConfigRaw(name string, data []byte) error {
-	resp, err := p.goCDRequest("PUT",
+	return p.SetPipelineConfigRawContext(context.Background(), name, data)
+}
+
+func (p *Client) SetPipelineConfigRawContext(ctx context.Context, name string, data []byte) error {
+	accept, err := p.accept(ctx, apiPipelineConfig)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "PUT",
 		fmt.Sprintf("%s/go/api/admin/pipelines/%s", p.host, name),
 		data,
-		map[string]string{"If-Match": p.Etag,
+		map[string]string{"If-Match": p.etags.get(etagPipeline, name),
 			"Content-Type": "application/json",
-			"Accept":       "application/vnd.go.cd.v2+json"})
+			"Accept":       accept})
 
 	switch true {
 	case err != nil:
@@ -207,29 +365,63 @@ func (p *Client) SetPipelineConfigRaw(name string, data []byte) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
-		if tag := resp.Header["Etag"]; len(tag) > 0 {
-			p.Etag = tag[0]
-		}
+		resp.Body.Close()
+		p.etags.update(etagPipeline, name, resp)
 		return nil
 	}
 }
 
+func (p *Client) UpdatePipelineConfig(name string, update func(*PipelineConfig) error) (*PipelineConfig, error) {
+	return p.UpdatePipelineConfigContext(context.Background(), name, update)
+}
+
+func (p *Client) UpdatePipelineConfigContext(ctx context.Context, name string, update func(*PipelineConfig) error) (*PipelineConfig, error) {
+	var pipeline *PipelineConfig
+	err := p.retryConflict(func() error {
+		var err error
+		if pipeline, err = p.GetPipelineConfigContext(ctx, name); err != nil {
+			return err
+		}
+		if err := update(pipeline); err != nil {
+			return err
+		}
+		return p.SetPipelineConfigContext(ctx, pipeline)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
 func (p *Client) DeletePipelineConfig(name string) error {
-	pipeline, env, err := p.FindPipelineConfig(name)
-	if pipeline == nil {
+	return p.DeletePipelineConfigContext(context.Background(), name)
+}
+
+func (p *Client) DeletePipelineConfigContext(ctx context.Context, name string) error {
+	pipeline, env, err := p.FindPipelineConfigContext(ctx, name)
+	if err != nil {
+		return err
+	} else if pipeline == nil {
 		return fmt.Errorf("%s not found", name)
 	}
 	if env != nil {
+		if env, err = p.GetEnvironmentContext(ctx, env.Name); err != nil {
+			return err
+		}
 		env.DeletePipeline(name)
-		if err := p.SetEnvironment(env); err != nil {
+		if err := p.SetEnvironmentContext(ctx, env); err != nil {
 			return err
 		}
 	}
 
-	resp, err := p.goCDRequest("DELETE",
+	accept, err := p.accept(ctx, apiPipelineConfig)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "DELETE",
 		fmt.Sprintf("%s/go/api/admin/pipelines/%s", p.host, name),
 		[]byte{},
-		map[string]string{"Accept": "application/vnd.go.cd.v2+json"})
+		map[string]string{"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -237,53 +429,75 @@ func (p *Client) DeletePipelineConfig(name string) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
+		p.etags.delete(etagPipeline, name)
 		return nil
 	}
 }
 
 func (p *Client) GetEnvironments() (*Environments, error) {
-	resp, err := p.goCDRequest("GET",
+	return p.GetEnvironmentsContext(context.Background())
+}
+
+func (p *Client) GetEnvironmentsContext(ctx context.Context) (*Environments, error) {
+	accept, err := p.accept(ctx, apiEnvironments)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
 		fmt.Sprintf("%s/go/api/admin/environments", p.host),
 		[]byte{},
-		map[string]string{"Accept": "application/vnd.go.cd.v1+json"})
+		map[string]string{"Accept": accept})
 
 	switch true {
 	case err != nil:
 		return nil, err
 	case resp.StatusCode != http.StatusOK:
 		return nil, p.createError(resp)
-	default:
-		if tag := resp.Header["Etag"]; len(tag) > 0 {
-			p.EtagEnv = tag[0]
-		}
 	}
 
 	envs := NewEnvironments()
-	return envs, p.unmarshal(resp.Body, envs)
+	if err := p.unmarshal(resp.Body, envs); err != nil {
+		return nil, err
+	}
+	envs.Etag = resp.Header.Get("Etag")
+	return envs, nil
 }
 
 func (p *Client) GetEnvironment(name string) (*Environment, error) {
-	resp, err := p.goCDRequest("GET",
+	return p.GetEnvironmentContext(context.Background(), name)
+}
+
+func (p *Client) GetEnvironmentContext(ctx context.Context, name string) (*Environment, error) {
+	accept, err := p.accept(ctx, apiEnvironments)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
 		fmt.Sprintf("%s/go/api/admin/environments/%s", p.host, name),
 		[]byte{},
-		map[string]string{"Accept": "application/vnd.go.cd.v1+json"})
+		map[string]string{"Accept": accept})
 
 	switch true {
 	case err != nil:
 		return nil, err
 	case resp.StatusCode != http.StatusOK:
 		return nil, p.createError(resp)
-	default:
-		if tag := resp.Header["Etag"]; len(tag) > 0 {
-			p.EtagEnv = tag[0]
-		}
 	}
 
 	env := NewEnvironment()
-	return env, p.unmarshal(resp.Body, env)
+	if err := p.unmarshal(resp.Body, env); err != nil {
+		return nil, err
+	}
+	env.Etag = p.etags.update(etagEnvironment, name, resp)
+	return env, nil
 }
 
 func (p *Client) NewEnvironment(env *Environment) error {
+	return p.NewEnvironmentContext(context.Background(), env)
+}
+
+func (p *Client) NewEnvironmentContext(ctx context.Context, env *Environment) error {
 	data := struct {
 		Name                 string                   `json:"name"`
 		Pipelines            []map[string]string      `json:"pipelines"`
@@ -303,11 +517,15 @@ func (p *Client) NewEnvironment(env *Environment) error {
 		return err
 	}
 
-	resp, err := p.goCDRequest("POST",
+	accept, err := p.accept(ctx, apiEnvironments)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
 		fmt.Sprintf("%s/go/api/admin/environments", p.host),
 		body,
 		map[string]string{"Content-Type": "application/json",
-			"Accept": "application/vnd.go.cd.v1+json"})
+			"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -315,14 +533,17 @@ func (p *Client) NewEnvironment(env *Environment) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
-		if tag := resp.Header["Etag"]; len(tag) > 0 {
-			p.EtagEnv = tag[0]
-		}
+		resp.Body.Close()
+		env.Etag = p.etags.update(etagEnvironment, env.Name, resp)
 		return nil
 	}
 }
 
 func (p *Client) SetEnvironment(env *Environment) error {
+	return p.SetEnvironmentContext(context.Background(), env)
+}
+
+func (p *Client) SetEnvironmentContext(ctx context.Context, env *Environment) error {
 	data := struct {
 		Name                 string                   `json:"name"`
 		Pipelines            []map[string]string      `json:"pipelines"`
@@ -343,14 +564,25 @@ func (p *Client) SetEnvironment(env *Environment) error {
 		return err
 	}
 
-	p.GetEnvironment(env.Name)
+	etag := p.etags.ifMatch(etagEnvironment, env.Name, env.Etag)
+	if etag == "" {
+		current, err := p.GetEnvironmentContext(ctx, env.Name)
+		if err != nil {
+			return err
+		}
+		etag = current.Etag
+	}
 
-	resp, err := p.goCDRequest("PUT",
+	accept, err := p.accept(ctx, apiEnvironments)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "PUT",
 		fmt.Sprintf("%s/go/api/admin/environments/%s", p.host, env.Name),
 		body,
-		map[string]string{"If-Match": p.EtagEnv,
+		map[string]string{"If-Match": etag,
 			"Content-Type": "application/json",
-			"Accept":       "application/vnd.go.cd.v1+json"})
+			"Accept":       accept})
 
 	switch true {
 	case err != nil:
@@ -358,19 +590,48 @@ func (p *Client) SetEnvironment(env *Environment) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
-		if tag := resp.Header["Etag"]; len(tag) > 0 {
-			p.EtagEnv = tag[0]
-		}
+		resp.Body.Close()
+		env.Etag = p.etags.update(etagEnvironment, env.Name, resp)
 		return nil
 	}
 }
 
+func (p *Client) UpdateEnvironment(name string, update func(*Environment) error) (*Environment, error) {
+	return p.UpdateEnvironmentContext(context.Background(), name, update)
+}
+
+func (p *Client) UpdateEnvironmentContext(ctx context.Context, name string, update func(*Environment) error) (*Environment, error) {
+	var env *Environment
+	err := p.retryConflict(func() error {
+		var err error
+		if env, err = p.GetEnvironmentContext(ctx, name); err != nil {
+			return err
+		}
+		if err := update(env); err != nil {
+			return err
+		}
+		return p.SetEnvironmentContext(ctx, env)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
 func (p *Client) DeleteEnvironment(name string) error {
-	resp, err := p.goCDRequest("DELETE",
+	return p.DeleteEnvironmentContext(context.Background(), name)
+}
+
+func (p *Client) DeleteEnvironmentContext(ctx context.Context, name string) error {
+	accept, err := p.accept(ctx, apiEnvironments)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "DELETE",
 		fmt.Sprintf("%s/go/api/admin/environments/%s", p.host, name),
 		[]byte{},
-		map[string]string{"If-Match": p.EtagEnv,
-			"Accept": "application/vnd.go.cd.v1+json"})
+		map[string]string{"If-Match": p.etags.get(etagEnvironment, name),
+			"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -378,15 +639,25 @@ func (p *Client) DeleteEnvironment(name string) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
+		p.etags.delete(etagEnvironment, name)
 		return nil
 	}
 }
 
 func (p *Client) UnpausePipeline(name string) error {
-	resp, err := p.goCDRequest("POST",
+	return p.UnpausePipelineContext(context.Background(), name)
+}
+
+func (p *Client) UnpausePipelineContext(ctx context.Context, name string) error {
+	accept, err := p.accept(ctx, apiPipelineOps)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
 		fmt.Sprintf("%s/go/api/pipelines/%s/unpause", p.host, name),
 		[]byte{},
-		map[string]string{"Confirm": "true"})
+		withAccept(map[string]string{"Confirm": "true", "X-GoCD-Confirm": "true"}, accept))
 
 	switch true {
 	case err != nil:
@@ -394,47 +665,64 @@ func (p *Client) UnpausePipeline(name string) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
 		return nil
 	}
 }
 
-func (p *Client) PausePipeline(name string) error {
-	resp, err := p.goCDRequest("POST",
-		fmt.Sprintf("%s/go/api/pipelines/%s/pause", p.host, name),
-		[]byte{'p', 'a', 'u', 's', 'e', 'C', 'a', 'u', 's', 'e', '=', 't', 'a', 'k', 'e', ' ', 's', 'o', 'm', 'e', ' ', 'r', 'e', 's', 't'},
-		map[string]string{"Confirm": "true"})
+func (p *Client) PausePipeline(name string, reason string) error {
+	return p.PausePipelineContext(context.Background(), name, reason)
+}
 
-	switch true {
-	case err != nil:
+// PausePipelineContext pauses the pipeline, recording reason as the cause.
+// Servers before 18.2.0 get it form encoded, newer ones as JSON.
+func (p *Client) PausePipelineContext(ctx context.Context, name string, reason string) error {
+	accept, err := p.accept(ctx, apiPipelineOps)
+	if err != nil {
 		return err
-	case resp.StatusCode != http.StatusOK:
-		return p.createError(resp)
-	default:
-		return nil
 	}
-}
 
-func (p *Client) SchedulePipeline(name string, data []byte) error {
-	resp, err := p.goCDRequest("POST",
-		fmt.Sprintf("%s/go/api/pipelines/%s/schedule", p.host, name),
-		data,
-		map[string]string{"Confirm": "true"})
+	headers := withAccept(map[string]string{"Confirm": "true", "X-GoCD-Confirm": "true"}, accept)
+	var body []byte
+	if accept == "" {
+		headers["Content-Type"] = "application/x-www-form-urlencoded"
+		body = []byte(url.Values{"pauseCause": {reason}}.Encode())
+	} else {
+		headers["Content-Type"] = "application/json"
+		if body, err = json.Marshal(map[string]string{"pause_cause": reason}); err != nil {
+			return err
+		}
+	}
+
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/pipelines/%s/pause", p.host, name),
+		body,
+		headers)
 
 	switch true {
 	case err != nil:
 		return err
-	case resp.StatusCode != http.StatusAccepted:
+	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
 		return nil
 	}
 }
 
-func (p *Client) GetGroups() (*[]*Group, error) {
-	resp, err := p.goCDRequest("GET",
-		fmt.Sprintf("%s/go/api/config/pipeline_groups", p.host),
+func (p *Client) GetPipelineStatus(name string) (*PipelineStatus, error) {
+	return p.GetPipelineStatusContext(context.Background(), name)
+}
+
+func (p *Client) GetPipelineStatusContext(ctx context.Context, name string) (*PipelineStatus, error) {
+	accept, err := p.accept(ctx, apiPipelineOps)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/pipelines/%s/status", p.host, name),
 		[]byte{},
-		map[string]string{})
+		withAccept(map[string]string{}, accept))
 
 	switch true {
 	case err != nil:
@@ -443,19 +731,29 @@ func (p *Client) GetGroups() (*[]*Group, error) {
 		return nil, p.createError(resp)
 	}
 
-	groups := make([]*Group, 0)
-	if err := p.unmarshal(resp.Body, &groups); err != nil {
-		return nil, err
-	} else {
-		return &groups, nil
-	}
+	status := PipelineStatus{}
+	return &status, p.unmarshal(resp.Body, &status)
 }
 
-func (p *Client) StageCancel(pipeline string, stage string) error {
-	resp, err := p.goCDRequest("POST",
-		fmt.Sprintf("%s/go/api/stages/%s/%s/cancel", p.host, pipeline, stage),
-		make([]byte, 0),
-		map[string]string{"Confirm": "true"})
+func (p *Client) ReleasePipelineLock(name string) error {
+	return p.ReleasePipelineLockContext(context.Background(), name)
+}
+
+// ReleasePipelineLockContext unlocks a locked pipeline, through unlock on
+// servers since 18.2.0 and releaseLock on older ones.
+func (p *Client) ReleasePipelineLockContext(ctx context.Context, name string) error {
+	accept, err := p.accept(ctx, apiPipelineOps)
+	if err != nil {
+		return err
+	}
+	action := "unlock"
+	if accept == "" {
+		action = "releaseLock"
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/pipelines/%s/%s", p.host, name, action),
+		[]byte{},
+		withAccept(map[string]string{"Confirm": "true", "X-GoCD-Confirm": "true"}, accept))
 
 	switch true {
 	case err != nil:
@@ -463,51 +761,92 @@ func (p *Client) StageCancel(pipeline string, stage string) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
 		return nil
 	}
 }
 
-func (p *Client) GetStageInstance(pipeline string, pInst int, stage string, sInst int) (*Stage, error) {
-	resp, err := p.goCDRequest("GET",
-		fmt.Sprintf("%s/go/api/stages/%s/%s/instance/%d/%d", p.host, pipeline, stage, pInst, sInst),
-		make([]byte, 0),
-		map[string]string{})
+func (p *Client) IsPipelinePaused(name string) (bool, string, string, error) {
+	return p.IsPipelinePausedContext(context.Background(), name)
+}
 
-	switch true {
-	case err != nil:
-		return nil, err
-	case resp.StatusCode != http.StatusOK:
-		return nil, p.createError(resp)
+// IsPipelinePausedContext reports whether the pipeline is paused, and if so
+// who paused it and why.
+func (p *Client) IsPipelinePausedContext(ctx context.Context, name string) (paused bool, by string, cause string, err error) {
+	status, err := p.GetPipelineStatusContext(ctx, name)
+	if err != nil {
+		return false, "", "", err
 	}
+	return status.Paused, status.PausedBy, status.PausedCause, nil
+}
 
-	s := NewStage()
-	return s, p.unmarshal(resp.Body, s)
+func (p *Client) SchedulePipeline(name string, options *ScheduleOptions) (int, error) {
+	return p.SchedulePipelineContext(context.Background(), name, options)
 }
 
-func (p *Client) GetStageInstanceHystory(pipeline string, stage string) ([]*Stage, error) {
-	resp, err := p.goCDRequest("GET",
-		fmt.Sprintf("%s/go/api/stages/%s/%s/history", p.host, pipeline, stage),
-		make([]byte, 0),
-		map[string]string{})
+// SchedulePipelineContext triggers the pipeline, overriding material
+// revisions and environment variables as given by options, which may be nil.
+// It returns the counter of the scheduled instance, or 0 when the server does
+// not tell.
+func (p *Client) SchedulePipelineContext(ctx context.Context, name string, options *ScheduleOptions) (int, error) {
+	if options == nil {
+		options = NewScheduleOptions()
+	}
+	accept, err := p.accept(ctx, apiPipelineOps)
+	if err != nil {
+		return 0, err
+	}
+
+	headers := withAccept(map[string]string{"Confirm": "true", "X-GoCD-Confirm": "true"}, accept)
+	var body []byte
+	if accept == "" {
+		headers["Content-Type"] = "application/x-www-form-urlencoded"
+		body = options.form()
+	} else {
+		headers["Content-Type"] = "application/json"
+		if body, err = json.Marshal(options); err != nil {
+			return 0, err
+		}
+	}
+
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/pipelines/%s/schedule", p.host, name),
+		body,
+		headers)
 
 	switch true {
 	case err != nil:
-		return nil, err
-	case resp.StatusCode != http.StatusOK:
-		return nil, p.createError(resp)
+		return 0, err
+	case resp.StatusCode != http.StatusAccepted:
+		return 0, p.createError(resp)
 	}
 
-	stages := struct {
-		Stages []*Stage `json:"stages"`
-	}{Stages: make([]*Stage, 0)}
-	return stages.Stages, p.unmarshal(resp.Body, &stages)
+	result := struct {
+		Counter         int `json:"counter"`
+		PipelineCounter int `json:"pipeline_counter"`
+	}{}
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		if err := p.unmarshal(resp.Body, &result); err != nil {
+			return 0, err
+		}
+	} else {
+		resp.Body.Close()
+	}
+	if result.Counter == 0 {
+		result.Counter = result.PipelineCounter
+	}
+	return result.Counter, nil
 }
 
-func (p *Client) GetAllAgents() ([]*Agent, error) {
-	resp, err := p.goCDRequest("GET",
-		fmt.Sprintf("%s/go/api/agents", p.host),
-		make([]byte, 0),
-		map[string]string{"Accept": "application/vnd.go.cd.v2+json"})
+func (p *Client) GetGroups() (*[]*Group, error) {
+	return p.GetGroupsContext(context.Background())
+}
+
+func (p *Client) GetGroupsContext(ctx context.Context) (*[]*Group, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/config/pipeline_groups", p.host),
+		[]byte{},
+		map[string]string{})
 
 	switch true {
 	case err != nil:
@@ -516,22 +855,1078 @@ func (p *Client) GetAllAgents() ([]*Agent, error) {
 		return nil, p.createError(resp)
 	}
 
-	data := struct {
-		Embeded struct {
-			Agents []*Agent `json:"agents"`
-		} `json:"_embedded"`
-	}{Embeded: struct {
-		Agents []*Agent `json:"agents"`
-	}{Agents: make([]*Agent, 0)}}
-
-	return data.Embeded.Agents, p.unmarshal(resp.Body, &data)
+	groups := make([]*Group, 0)
+	if err := p.unmarshal(resp.Body, &groups); err != nil {
+		return nil, err
+	} else {
+		return &groups, nil
+	}
+}
+
+func (p *Client) GetPipelineGroup(name string) (*PipelineGroupConfig, error) {
+	return p.GetPipelineGroupContext(context.Background(), name)
+}
+
+func (p *Client) GetPipelineGroupContext(ctx context.Context, name string) (*PipelineGroupConfig, error) {
+	accept, err := p.accept(ctx, apiGroups)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/admin/pipeline_groups/%s", p.host, name),
+		[]byte{},
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	group := NewPipelineGroupConfig(name)
+	if err := p.unmarshal(resp.Body, group); err != nil {
+		return nil, err
+	}
+	group.Etag = p.etags.update(etagGroup, name, resp)
+	return group, nil
+}
+
+func (p *Client) NewPipelineGroup(group *PipelineGroupConfig) error {
+	return p.NewPipelineGroupContext(context.Background(), group)
+}
+
+func (p *Client) NewPipelineGroupContext(ctx context.Context, group *PipelineGroupConfig) error {
+	data := struct {
+		Name          string             `json:"name"`
+		Authorization GroupAuthorization `json:"authorization"`
+	}{Name: group.Name, Authorization: group.Authorization}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	accept, err := p.accept(ctx, apiGroups)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/admin/pipeline_groups", p.host),
+		body,
+		map[string]string{"Content-Type": "application/json",
+			"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		group.Etag = p.etags.update(etagGroup, group.Name, resp)
+		return nil
+	}
+}
+
+func (p *Client) SetPipelineGroup(group *PipelineGroupConfig) error {
+	return p.SetPipelineGroupContext(context.Background(), group)
+}
+
+// SetPipelineGroupContext replaces the group with the ETag it was fetched
+// with; use UpdatePipelineGroupContext to change a group in one call.
+func (p *Client) SetPipelineGroupContext(ctx context.Context, group *PipelineGroupConfig) error {
+	data := struct {
+		Name          string             `json:"name"`
+		Authorization GroupAuthorization `json:"authorization"`
+	}{Name: group.Name, Authorization: group.Authorization}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	etag := p.etags.ifMatch(etagGroup, group.Name, group.Etag)
+	if etag == "" {
+		return fmt.Errorf("%w: fetch pipeline group %s with GetPipelineGroup or change it with UpdatePipelineGroup", ErrNoEtag, group.Name)
+	}
+
+	accept, err := p.accept(ctx, apiGroups)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "PUT",
+		fmt.Sprintf("%s/go/api/admin/pipeline_groups/%s", p.host, group.Name),
+		body,
+		map[string]string{"If-Match": etag,
+			"Content-Type": "application/json",
+			"Accept":       accept})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		group.Etag = p.etags.update(etagGroup, group.Name, resp)
+		return nil
+	}
+}
+
+func (p *Client) UpdatePipelineGroup(name string, update func(*PipelineGroupConfig) error) (*PipelineGroupConfig, error) {
+	return p.UpdatePipelineGroupContext(context.Background(), name, update)
+}
+
+func (p *Client) UpdatePipelineGroupContext(ctx context.Context, name string, update func(*PipelineGroupConfig) error) (*PipelineGroupConfig, error) {
+	var group *PipelineGroupConfig
+	err := p.retryConflict(func() error {
+		var err error
+		if group, err = p.GetPipelineGroupContext(ctx, name); err != nil {
+			return err
+		}
+		if err := update(group); err != nil {
+			return err
+		}
+		return p.SetPipelineGroupContext(ctx, group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (p *Client) SetPipelineGroupAuthorization(name string, authorization GroupAuthorization) error {
+	return p.SetPipelineGroupAuthorizationContext(context.Background(), name, authorization)
+}
+
+func (p *Client) SetPipelineGroupAuthorizationContext(ctx context.Context, name string, authorization GroupAuthorization) error {
+	_, err := p.UpdatePipelineGroupContext(ctx, name, func(group *PipelineGroupConfig) error {
+		group.Authorization = authorization
+		return nil
+	})
+	return err
+}
+
+func (p *Client) DeletePipelineGroup(name string) error {
+	return p.DeletePipelineGroupContext(context.Background(), name)
+}
+
+// DeletePipelineGroupContext deletes the group, which must not contain any
+// pipeline.
+func (p *Client) DeletePipelineGroupContext(ctx context.Context, name string) error {
+	accept, err := p.accept(ctx, apiGroups)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "DELETE",
+		fmt.Sprintf("%s/go/api/admin/pipeline_groups/%s", p.host, name),
+		[]byte{},
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		p.etags.delete(etagGroup, name)
+		return nil
+	}
+}
+
+func (p *Client) RenamePipelineGroup(name, newName string) error {
+	return p.RenamePipelineGroupContext(context.Background(), name, newName)
+}
+
+// RenamePipelineGroupContext renames the group in cruise-config.xml, keeping
+// its authorization and pipelines.
+func (p *Client) RenamePipelineGroupContext(ctx context.Context, name, newName string) error {
+	err := p.editConfigXML(ctx, func(config []byte) ([]byte, error) {
+		return renameConfigGroup(config, name, newName)
+	})
+	if err != nil {
+		return err
+	}
+	p.etags.delete(etagGroup, name)
+	return nil
+}
+
+func (p *Client) MovePipelineToGroup(pipeline, group string) error {
+	return p.MovePipelineToGroupContext(context.Background(), pipeline, group)
+}
+
+// MovePipelineToGroupContext moves the pipeline to another group by editing
+// cruise-config.xml, so the pipeline is never deleted and keeps its config,
+// environment and run history. Pipelines defined in config repositories
+// cannot be moved.
+func (p *Client) MovePipelineToGroupContext(ctx context.Context, pipeline, group string) error {
+	return p.editConfigXML(ctx, func(config []byte) ([]byte, error) {
+		return moveConfigPipeline(config, pipeline, group)
+	})
+}
+
+func (p *Client) StageCancel(pipeline string, stage string) error {
+	return p.StageCancelContext(context.Background(), pipeline, stage)
+}
+
+func (p *Client) StageCancelContext(ctx context.Context, pipeline string, stage string) error {
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/stages/%s/%s/cancel", p.host, pipeline, stage),
+		make([]byte, 0),
+		map[string]string{"Confirm": "true"})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		return nil
+	}
+}
+
+func (p *Client) GetStageInstance(pipeline string, pInst int, stage string, sInst int) (*Stage, error) {
+	return p.GetStageInstanceContext(context.Background(), pipeline, pInst, stage, sInst)
+}
+
+func (p *Client) GetStageInstanceContext(ctx context.Context, pipeline string, pInst int, stage string, sInst int) (*Stage, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/stages/%s/%s/instance/%d/%d", p.host, pipeline, stage, pInst, sInst),
+		make([]byte, 0),
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	s := NewStage()
+	return s, p.unmarshal(resp.Body, s)
+}
+
+func (p *Client) GetStageInstanceHystory(pipeline string, stage string) ([]*Stage, error) {
+	return p.GetStageInstanceHystoryContext(context.Background(), pipeline, stage)
+}
+
+func (p *Client) GetStageInstanceHystoryContext(ctx context.Context, pipeline string, stage string) ([]*Stage, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/stages/%s/%s/history", p.host, pipeline, stage),
+		make([]byte, 0),
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	stages := struct {
+		Stages []*Stage `json:"stages"`
+	}{Stages: make([]*Stage, 0)}
+	return stages.Stages, p.unmarshal(resp.Body, &stages)
+}
+
+func (p *Client) GetAllAgents() ([]*Agent, error) {
+	return p.GetAllAgentsContext(context.Background())
+}
+
+func (p *Client) GetAllAgentsContext(ctx context.Context) ([]*Agent, error) {
+	accept, err := p.accept(ctx, apiAgents)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/agents", p.host),
+		make([]byte, 0),
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	data := struct {
+		Embeded struct {
+			Agents []*Agent `json:"agents"`
+		} `json:"_embedded"`
+	}{Embeded: struct {
+		Agents []*Agent `json:"agents"`
+	}{Agents: make([]*Agent, 0)}}
+
+	return data.Embeded.Agents, p.unmarshal(resp.Body, &data)
+}
+
+func (p *Client) GetAgent(uuid string) (*Agent, error) {
+	return p.GetAgentContext(context.Background(), uuid)
+}
+
+func (p *Client) GetAgentContext(ctx context.Context, uuid string) (*Agent, error) {
+	accept, err := p.accept(ctx, apiAgents)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/agents/%s", p.host, uuid),
+		make([]byte, 0),
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	agent := NewAgent()
+	return agent, p.unmarshal(resp.Body, agent)
+}
+
+func (p *Client) SetAgent(agent Agent) error {
+	return p.SetAgentContext(context.Background(), agent)
+}
+
+func (p *Client) SetAgentContext(ctx context.Context, agent Agent) error {
+	old_agent, err := p.GetAgentContext(ctx, agent.Uuid)
+	if err != nil {
+		return err
+	}
+	return p.patchAgent(ctx, *old_agent, agent)
+}
+
+func (p *Client) UpdateAgent(uuid string, update func(*Agent) error) (*Agent, error) {
+	return p.UpdateAgentContext(context.Background(), uuid, update)
+}
+
+func (p *Client) UpdateAgentContext(ctx context.Context, uuid string, update func(*Agent) error) (*Agent, error) {
+	var agent Agent
+	err := p.retryConflict(func() error {
+		old_agent, err := p.GetAgentContext(ctx, uuid)
+		if err != nil {
+			return err
+		}
+		agent = *old_agent
+		agent.Resources = append(old_agent.Resources[:0:0], old_agent.Resources...)
+		agent.Environments = append(old_agent.Environments[:0:0], old_agent.Environments...)
+		if err := update(&agent); err != nil {
+			return err
+		}
+		return p.patchAgent(ctx, *old_agent, agent)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+func (p *Client) patchAgent(ctx context.Context, old_agent Agent, agent Agent) error {
+	diff := old_agent.Diff(agent)
+	if reflect.DeepEqual(diff, make(map[string]interface{})) {
+		return nil
+	}
+	body, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	accept, err := p.accept(ctx, apiAgents)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "PATCH",
+		fmt.Sprintf("%s/go/api/agents/%s", p.host, agent.Uuid),
+		body,
+		map[string]string{"Accept": accept,
+			"Content-Type": "application/json"})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		return nil
+	}
+}
+
+func (p *Client) DeleteAgent(uuid string) error {
+	return p.DeleteAgentContext(context.Background(), uuid)
+}
+
+func (p *Client) DeleteAgentContext(ctx context.Context, uuid string) error {
+	accept, err := p.accept(ctx, apiAgents)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "DELETE",
+		fmt.Sprintf("%s/go/api/agents/%s", p.host, uuid),
+		make([]byte, 0),
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		return nil
+	}
+}
+
+func (p *Client) GetAgentJobHistory(uuid string, offset int) (*JobHistory, error) {
+	return p.GetAgentJobHistoryContext(context.Background(), uuid, offset)
+}
+
+func (p *Client) GetAgentJobHistoryContext(ctx context.Context, uuid string, offset int) (*JobHistory, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/agents/%s/job_run_history/%d", p.host, uuid, offset),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	history := NewJobHistory()
+	return history, p.unmarshal(resp.Body, history)
+}
+
+func (p *Client) IterateAgentJobHistory(uuid string) *JobIterator {
+	return p.IterateAgentJobHistoryContext(context.Background(), uuid)
+}
+
+func (p *Client) IterateAgentJobHistoryContext(ctx context.Context, uuid string) *JobIterator {
+	return newJobIterator(ctx, func(ctx context.Context, offset int) (*JobHistory, error) {
+		return p.GetAgentJobHistoryContext(ctx, uuid, offset)
+	})
+}
+
+func (p *Client) GetScheduledJobs() ([]*ScheduledJob, error) {
+	return p.GetScheduledJobsContext(context.Background())
+}
+
+func (p *Client) GetScheduledJobsContext(ctx context.Context) ([]*ScheduledJob, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/jobs/scheduled.xml", p.host),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	defer resp.Body.Close()
+	jobs := struct {
+		Jobs []*ScheduledJob `xml:"job"`
+	}{make([]*ScheduledJob, 0)}
+	return jobs.Jobs, xml.NewDecoder(resp.Body).Decode(&jobs)
+}
+
+func (p *Client) GetJobHistory(pipeline, stage, job string, offset int) (*JobHistory, error) {
+	return p.GetJobHistoryContext(context.Background(), pipeline, stage, job, offset)
+}
+
+func (p *Client) GetJobHistoryContext(ctx context.Context, pipeline, stage, job string, offset int) (*JobHistory, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/jobs/%s/%s/%s/history/%d", p.host, pipeline, stage, job, offset),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	history := NewJobHistory()
+	return history, p.unmarshal(resp.Body, history)
+}
+
+func (p *Client) IterateJobHistory(pipeline, stage, job string) *JobIterator {
+	return p.IterateJobHistoryContext(context.Background(), pipeline, stage, job)
+}
+
+func (p *Client) IterateJobHistoryContext(ctx context.Context, pipeline, stage, job string) *JobIterator {
+	return newJobIterator(ctx, func(ctx context.Context, offset int) (*JobHistory, error) {
+		return p.GetJobHistoryContext(ctx, pipeline, stage, job, offset)
+	})
+}
+
+func (p *Client) GetJobProperties(pipeline string, pCounter int, stage string, sCounter int, job string) (JobProperties, error) {
+	return p.GetJobPropertiesContext(context.Background(), pipeline, pCounter, stage, sCounter, job)
+}
+
+func (p *Client) GetJobPropertiesContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string) (JobProperties, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		propertiesURL(p.host, pipeline, pCounter, stage, sCounter, job),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	defer resp.Body.Close()
+	rows, err := parseProperties(resp.Body)
+	if err != nil || len(rows) == 0 {
+		return JobProperties{}, err
+	}
+	return rows[0], nil
+}
+
+func (p *Client) GetJobProperty(pipeline string, pCounter int, stage string, sCounter int, job string, name string) (string, error) {
+	return p.GetJobPropertyContext(context.Background(), pipeline, pCounter, stage, sCounter, job, name)
+}
+
+func (p *Client) GetJobPropertyContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string, name string) (string, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		propertiesURL(p.host, pipeline, pCounter, stage, sCounter, job)+"/"+url.PathEscape(name),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return "", err
+	case resp.StatusCode != http.StatusOK:
+		return "", p.createError(resp)
+	}
+
+	defer resp.Body.Close()
+	rows, err := parseProperties(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) > 0 {
+		if value, ok := rows[0][name]; ok {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("Property %s is not set on %s/%d/%s/%d/%s", name, pipeline, pCounter, stage, sCounter, job)
+}
+
+func (p *Client) GetHistoricalJobProperties(pipeline, stage, job string, limitPipeline string, limitCount int) ([]*HistoricalJobProperties, error) {
+	return p.GetHistoricalJobPropertiesContext(context.Background(), pipeline, stage, job, limitPipeline, limitCount)
+}
+
+// GetHistoricalJobPropertiesContext returns the properties of past runs of a
+// job, oldest first. limitPipeline is the counter of the newest pipeline
+// instance to include, or "" for the latest, and limitCount caps the number
+// of runs when positive.
+func (p *Client) GetHistoricalJobPropertiesContext(ctx context.Context, pipeline, stage, job string, limitPipeline string, limitCount int) ([]*HistoricalJobProperties, error) {
+	query := url.Values{"pipelineName": {pipeline}, "stageName": {stage}, "jobName": {job}}
+	if limitPipeline != "" {
+		query.Set("limitPipeline", limitPipeline)
+	}
+	if limitCount > 0 {
+		query.Set("limitCount", strconv.Itoa(limitCount))
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/properties/search?%s", p.host, query.Encode()),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	defer resp.Body.Close()
+	rows, err := parseProperties(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*HistoricalJobProperties, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, newHistoricalJobProperties(row))
+	}
+	return result, nil
+}
+
+func (p *Client) CreateJobProperty(pipeline string, pCounter int, stage string, sCounter int, job string, name string, value string) error {
+	return p.CreateJobPropertyContext(context.Background(), pipeline, pCounter, stage, sCounter, job, name, value)
+}
+
+func (p *Client) CreateJobPropertyContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string, name string, value string) error {
+	resp, err := p.goCDRequest(ctx, "POST",
+		propertiesURL(p.host, pipeline, pCounter, stage, sCounter, job)+"/"+url.PathEscape(name),
+		[]byte(url.Values{"value": {value}}.Encode()),
+		map[string]string{"Confirm": "true",
+			"Content-Type": "application/x-www-form-urlencoded"})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusCreated:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		return nil
+	}
+}
+
+func (p *Client) GetAllMaterials() ([]*Material, error) {
+	return p.GetAllMaterialsContext(context.Background())
+}
+
+func (p *Client) GetAllMaterialsContext(ctx context.Context) ([]*Material, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/config/materials", p.host),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	materials := make([]*Material, 0)
+	return materials, p.unmarshal(resp.Body, &materials)
+}
+
+func (p *Client) GetMaterialModifications(fingerprint string, offset int) (*MaterialModifications, error) {
+	return p.GetMaterialModificationsContext(context.Background(), fingerprint, offset)
+}
+
+func (p *Client) GetMaterialModificationsContext(ctx context.Context, fingerprint string, offset int) (*MaterialModifications, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/materials/%s/modifications/%d", p.host, fingerprint, offset),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	modifications := NewMaterialModifications()
+	return modifications, p.unmarshal(resp.Body, modifications)
+}
+
+func (p *Client) NotifyGitMaterial(repoURL string) error {
+	return p.NotifyGitMaterialContext(context.Background(), repoURL)
+}
+
+func (p *Client) NotifyGitMaterialContext(ctx context.Context, repoURL string) error {
+	return p.notifyMaterial(ctx, "git", url.Values{"repository_url": {repoURL}})
+}
+
+func (p *Client) NotifySVNMaterial(uuid string) error {
+	return p.NotifySVNMaterialContext(context.Background(), uuid)
+}
+
+func (p *Client) NotifySVNMaterialContext(ctx context.Context, uuid string) error {
+	return p.notifyMaterial(ctx, "svn", url.Values{"uuid": {uuid}})
+}
+
+func (p *Client) NotifySVNMaterialURL(repoURL string) error {
+	return p.NotifySVNMaterialURLContext(context.Background(), repoURL)
+}
+
+func (p *Client) NotifySVNMaterialURLContext(ctx context.Context, repoURL string) error {
+	return p.notifyMaterial(ctx, "svn", url.Values{"repository_url": {repoURL}})
+}
+
+func (p *Client) notifyMaterial(ctx context.Context, kind string, data url.Values) error {
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/material/notify/%s", p.host, kind),
+		[]byte(data.Encode()),
+		map[string]string{"Confirm": "true",
+			"Content-Type": "application/x-www-form-urlencoded"})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		return nil
+	}
+}
+
+func (p *Client) CreateBackup() (*Backup, error) {
+	return p.CreateBackupContext(context.Background())
+}
+
+// CreateBackupContext backs up the server. Servers from 19.3.0 only start the
+// backup and return its ID; use WaitForBackupContext to wait for it.
+func (p *Client) CreateBackupContext(ctx context.Context) (*Backup, error) {
+	accept, err := p.accept(ctx, apiBackups)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/backups", p.host),
+		[]byte{},
+		map[string]string{"Accept": accept,
+			"Confirm":        "true",
+			"X-GoCD-Confirm": "true"})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode == http.StatusAccepted:
+		resp.Body.Close()
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return nil, fmt.Errorf("Backup accepted without Location")
+		}
+		backup := NewBackup()
+		backup.ID, backup.Status = location[strings.LastIndex(location, "/")+1:], BackupNotStarted
+		return backup, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	backup := NewBackup()
+	if err := p.unmarshal(resp.Body, backup); err != nil {
+		return nil, err
+	}
+	if backup.Status == "" {
+		backup.Status = BackupCompleted
+	}
+	return backup, nil
+}
+
+func (p *Client) GetBackup(id string) (*Backup, error) {
+	return p.GetBackupContext(context.Background(), id)
+}
+
+func (p *Client) GetBackupContext(ctx context.Context, id string) (*Backup, error) {
+	accept, err := p.accept(ctx, apiBackupStatus)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/backups/%s", p.host, id),
+		[]byte{},
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	backup := NewBackup()
+	backup.ID = id
+	return backup, p.unmarshal(resp.Body, backup)
+}
+
+func (p *Client) WaitForBackup(id string, interval time.Duration) (*Backup, error) {
+	return p.WaitForBackupContext(context.Background(), id, interval)
+}
+
+// WaitForBackupContext polls the backup every interval until it completes,
+// fails or ctx is done.
+func (p *Client) WaitForBackupContext(ctx context.Context, id string, interval time.Duration) (*Backup, error) {
+	if interval <= 0 {
+		interval = DefaultBackupPollInterval
+	}
+	for {
+		backup, err := p.GetBackupContext(ctx, id)
+		switch true {
+		case err != nil:
+			return nil, err
+		case backup.Status == BackupCompleted:
+			return backup, nil
+		case backup.Status == BackupError:
+			return backup, fmt.Errorf("Backup %s failed: %s", id, backup.Message)
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Client) CreateBackupAndWait(interval time.Duration) (*Backup, error) {
+	return p.CreateBackupAndWaitContext(context.Background(), interval)
+}
+
+func (p *Client) CreateBackupAndWaitContext(ctx context.Context, interval time.Duration) (*Backup, error) {
+	backup, err := p.CreateBackupContext(ctx)
+	if err != nil || backup.Done() {
+		return backup, err
+	}
+	return p.WaitForBackupContext(ctx, backup.ID, interval)
+}
+
+func (p *Client) ListArtifacts(pipeline string, pCounter int, stage string, sCounter int, job string) ([]*Artifact, error) {
+	return p.ListArtifactsContext(context.Background(), pipeline, pCounter, stage, sCounter, job)
+}
+
+func (p *Client) ListArtifactsContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string) ([]*Artifact, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		artifactURL(p.host, pipeline, pCounter, stage, sCounter, job, "")+".json",
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	artifacts := make([]*Artifact, 0)
+	return artifacts, p.unmarshal(resp.Body, &artifacts)
+}
+
+func (p *Client) DownloadArtifactFile(pipeline string, pCounter int, stage string, sCounter int, job string, path string, w io.Writer) error {
+	return p.DownloadArtifactFileContext(context.Background(), pipeline, pCounter, stage, sCounter, job, path, w)
+}
+
+// DownloadArtifactFileContext streams the artifact file at path to w.
+func (p *Client) DownloadArtifactFileContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string, path string, w io.Writer) error {
+	resp, err := p.goCDRequest(ctx, "GET",
+		artifactURL(p.host, pipeline, pCounter, stage, sCounter, job, path),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	}
+
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (p *Client) DownloadArtifactDirectory(pipeline string, pCounter int, stage string, sCounter int, job string, path string, w io.Writer) error {
+	return p.DownloadArtifactDirectoryContext(context.Background(), pipeline, pCounter, stage, sCounter, job, path, w)
+}
+
+// DownloadArtifactDirectoryContext streams the artifact directory at path to
+// w as a zip archive, waiting while the server is still creating it. Without
+// a deadline on ctx it gives up waiting after artifactMaxWait.
+func (p *Client) DownloadArtifactDirectoryContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string, path string, w io.Writer) error {
+	_, bounded := ctx.Deadline()
+	giveUp := time.Now().Add(artifactMaxWait)
+	for {
+		resp, err := p.goCDRequest(ctx, "GET",
+			artifactURL(p.host, pipeline, pCounter, stage, sCounter, job, path)+".zip",
+			[]byte{},
+			map[string]string{})
+
+		switch true {
+		case err != nil:
+			return err
+		case resp.StatusCode == http.StatusAccepted:
+			resp.Body.Close()
+			delay, ok := retryAfter(resp)
+			if !ok {
+				delay = artifactPollInterval
+			}
+			if !bounded && time.Now().Add(delay).After(giveUp) {
+				return fmt.Errorf("Artifact %s is still being zipped after %s", path, artifactMaxWait)
+			}
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		case resp.StatusCode != http.StatusOK:
+			return p.createError(resp)
+		}
+
+		defer resp.Body.Close()
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+}
+
+func (p *Client) ExtractArtifactDirectory(pipeline string, pCounter int, stage string, sCounter int, job string, path string, dir string) error {
+	return p.ExtractArtifactDirectoryContext(context.Background(), pipeline, pCounter, stage, sCounter, job, path, dir)
+}
+
+// ExtractArtifactDirectoryContext downloads the artifact directory at path
+// and unpacks it into dir. Archive entries that would land outside of dir
+// are rejected.
+func (p *Client) ExtractArtifactDirectoryContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string, path string, dir string) error {
+	tmp, err := ioutil.TempFile("", "gocd-artifact-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := p.DownloadArtifactDirectoryContext(ctx, pipeline, pCounter, stage, sCounter, job, path, tmp); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return err
+	}
+	return extractZip(archive, dir)
 }
 
-func (p *Client) GetAgent(uuid string) (*Agent, error) {
-	resp, err := p.goCDRequest("GET",
-		fmt.Sprintf("%s/go/api/agents/%s", p.host, uuid),
-		make([]byte, 0),
-		map[string]string{"Accept": "application/vnd.go.cd.v2+json"})
+func (p *Client) UploadArtifact(pipeline string, pCounter int, stage string, sCounter int, job string, path string, src string, progress ProgressFunc) error {
+	return p.UploadArtifactContext(context.Background(), pipeline, pCounter, stage, sCounter, job, path, src, progress)
+}
+
+// UploadArtifactContext uploads the local file or directory src to path in
+// the artifacts of a job; directories are sent as a zip archive the server
+// unpacks. The md5 of every file is sent along for the server to record;
+// WithUploadVerification also downloads every file again afterwards to check
+// its md5 matches the local one. progress may be nil.
+func (p *Client) UploadArtifactContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string, path string, src string, progress ProgressFunc) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() && path == "" {
+		path = filepath.Base(src)
+	}
+	path = strings.Trim(path, "/")
+
+	var upload *artifactUpload
+	if info.IsDir() {
+		tmp, err := ioutil.TempFile("", "gocd-artifact-*.zip")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		checksums, err := zipDirectory(src, path, tmp)
+		if err != nil {
+			return err
+		}
+		size, err := tmp.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if upload, err = newArtifactUpload("zipfile", filepath.Base(src)+".zip", tmp, size, checksums, progress); err != nil {
+			return err
+		}
+	} else {
+		checksum, err := fileChecksum(src)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if upload, err = newArtifactUpload("file", filepath.Base(src), file, info.Size(), map[string]string{path: checksum}, progress); err != nil {
+			return err
+		}
+	}
+
+	resp, err := p.goCDStream(ctx, "POST",
+		artifactURL(p.host, pipeline, pCounter, stage, sCounter, job, path),
+		upload.body,
+		upload.size,
+		map[string]string{
+			"Confirm":        "true",
+			"X-GoCD-Confirm": "true",
+			"Content-Type":   upload.contentType})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	}
+	resp.Body.Close()
+	if !p.verifyUploads {
+		return nil
+	}
+	return p.verifyArtifactFiles(ctx, pipeline, pCounter, stage, sCounter, job, upload.checksums)
+}
+
+func (p *Client) AppendToArtifact(pipeline string, pCounter int, stage string, sCounter int, job string, path string, r io.Reader, progress ProgressFunc) error {
+	return p.AppendToArtifactContext(context.Background(), pipeline, pCounter, stage, sCounter, job, path, r, progress)
+}
+
+// AppendToArtifactContext streams r to the end of the artifact file at path,
+// creating it when missing. Appends are never retried. progress may be nil.
+func (p *Client) AppendToArtifactContext(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string, path string, r io.Reader, progress ProgressFunc) error {
+	size := int64(-1)
+	if sized, ok := r.(interface{ Len() int }); ok {
+		size = int64(sized.Len())
+	}
+
+	resp, err := p.goCDStream(ctx, "PUT",
+		artifactURL(p.host, pipeline, pCounter, stage, sCounter, job, path),
+		newProgressReader(r, size, progress),
+		size,
+		map[string]string{
+			"Confirm":        "true",
+			"X-GoCD-Confirm": "true",
+			"Content-Type":   "application/octet-stream"})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		return nil
+	}
+}
+
+// verifyArtifactFiles downloads every uploaded file and compares its md5 with
+// the one computed locally before the upload.
+func (p *Client) verifyArtifactFiles(ctx context.Context, pipeline string, pCounter int, stage string, sCounter int, job string, expected map[string]string) error {
+	for _, name := range sortedKeys(expected) {
+		hash := md5.New()
+		if err := p.DownloadArtifactFileContext(ctx, pipeline, pCounter, stage, sCounter, job, name, hash); err != nil {
+			return err
+		}
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected[name] {
+			return fmt.Errorf("Artifact %s has checksum %q on the server, expected %q", name, actual, expected[name])
+		}
+	}
+	return nil
+}
+
+func (p *Client) GetConfigModifications() ([]*ConfigModification, error) {
+	return p.GetConfigModificationsContext(context.Background())
+}
+
+func (p *Client) GetConfigModificationsContext(ctx context.Context) ([]*ConfigModification, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/config/revisions", p.host),
+		[]byte{},
+		map[string]string{})
 
 	switch true {
 	case err != nil:
@@ -540,29 +1935,235 @@ func (p *Client) GetAgent(uuid string) (*Agent, error) {
 		return nil, p.createError(resp)
 	}
 
-	agent := NewAgent()
-	return agent, p.unmarshal(resp.Body, agent)
+	modifications := make([]*ConfigModification, 0)
+	return modifications, p.unmarshal(resp.Body, &modifications)
 }
 
-func (p *Client) SetAgent(agent Agent) error {
-	old_agent, err := p.GetAgent(agent.Uuid)
+func (p *Client) GetConfigDiff(from, to string) (string, error) {
+	return p.GetConfigDiffContext(context.Background(), from, to)
+}
+
+// GetConfigDiffContext returns the unified diff of the configuration between
+// two revisions, identified by the CommitSHA of a ConfigModification.
+func (p *Client) GetConfigDiffContext(ctx context.Context, from, to string) (string, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/config/diff/%s/%s", p.host, from, to),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return "", err
+	case resp.StatusCode != http.StatusOK:
+		return "", p.createError(resp)
+	}
+
+	defer resp.Body.Close()
+	diff, err := ioutil.ReadAll(resp.Body)
+	return string(diff), err
+}
+
+func (p *Client) GetConfigXML() ([]byte, string, error) {
+	return p.GetConfigXMLContext(context.Background())
+}
+
+// GetConfigXMLContext returns the current cruise-config.xml and its md5.
+func (p *Client) GetConfigXMLContext(ctx context.Context) ([]byte, string, error) {
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/admin/config.xml", p.host),
+		[]byte{},
+		map[string]string{})
+
+	switch true {
+	case err != nil:
+		return nil, "", err
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", p.createError(resp)
+	}
+
+	defer resp.Body.Close()
+	config, err := ioutil.ReadAll(resp.Body)
+	return config, resp.Header.Get("X-Cruise-Config-Md5"), err
+}
+
+// setConfigXML replaces cruise-config.xml. The server refuses the change with
+// a conflict when md5 is not that of its current config.
+func (p *Client) setConfigXML(ctx context.Context, config []byte, md5 string) error {
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/admin/config.xml", p.host),
+		[]byte(url.Values{"xmlFile": {string(config)}, "md5": {md5}}.Encode()),
+		map[string]string{"Confirm": "true",
+			"Content-Type": "application/x-www-form-urlencoded"})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		return nil
+	}
+}
+
+// editConfigXML applies edit to cruise-config.xml and saves the result,
+// starting over when the config changed since it was read.
+func (p *Client) editConfigXML(ctx context.Context, edit func([]byte) ([]byte, error)) error {
+	return p.retryConflict(func() error {
+		config, md5, err := p.GetConfigXMLContext(ctx)
+		if err != nil {
+			return err
+		}
+		edited, err := edit(config)
+		if err != nil || bytes.Equal(edited, config) {
+			return err
+		}
+		return p.setConfigXML(ctx, edited, md5)
+	})
+}
+
+func (p *Client) GetDashboard() (*Dashboard, error) {
+	return p.GetDashboardContext(context.Background())
+}
+
+func (p *Client) GetDashboardContext(ctx context.Context) (*Dashboard, error) {
+	accept, err := p.accept(ctx, apiDashboard)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/dashboard", p.host),
+		[]byte{},
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	dashboard := NewDashboard()
+	return dashboard, p.unmarshal(resp.Body, dashboard)
+}
+
+func (p *Client) GetTemplates() ([]*Template, error) {
+	return p.GetTemplatesContext(context.Background())
+}
+
+func (p *Client) GetTemplatesContext(ctx context.Context) ([]*Template, error) {
+	accept, err := p.accept(ctx, apiTemplates)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/admin/templates", p.host),
+		[]byte{},
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseTemplates(body)
+}
+
+func (p *Client) GetTemplate(name string) (*TemplateConfig, error) {
+	return p.GetTemplateContext(context.Background(), name)
+}
+
+func (p *Client) GetTemplateContext(ctx context.Context, name string) (*TemplateConfig, error) {
+	accept, err := p.accept(ctx, apiTemplates)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
+		fmt.Sprintf("%s/go/api/admin/templates/%s", p.host, name),
+		[]byte{},
+		map[string]string{"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return nil, err
+	case resp.StatusCode != http.StatusOK:
+		return nil, p.createError(resp)
+	}
+
+	template := NewTemplateConfig()
+	if err := p.unmarshal(resp.Body, template); err != nil {
+		return nil, err
+	}
+	template.Etag = p.etags.update(etagTemplate, name, resp)
+	return template, nil
+}
+
+func (p *Client) NewTemplate(template *TemplateConfig) error {
+	return p.NewTemplateContext(context.Background(), template)
+}
+
+func (p *Client) NewTemplateContext(ctx context.Context, template *TemplateConfig) error {
+	body, err := json.Marshal(template)
 	if err != nil {
 		return err
 	}
-	diff := old_agent.Diff(agent)
-	if reflect.DeepEqual(diff, make(map[string]interface{})) {
+
+	accept, err := p.accept(ctx, apiTemplates)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
+		fmt.Sprintf("%s/go/api/admin/templates", p.host),
+		body,
+		map[string]string{"Content-Type": "application/json",
+			"Accept": accept})
+
+	switch true {
+	case err != nil:
+		return err
+	case resp.StatusCode != http.StatusOK:
+		return p.createError(resp)
+	default:
+		resp.Body.Close()
+		template.Etag = p.etags.update(etagTemplate, template.Name, resp)
 		return nil
 	}
-	body, err := json.Marshal(diff)
+}
+
+func (p *Client) SetTemplate(template *TemplateConfig) error {
+	return p.SetTemplateContext(context.Background(), template)
+}
+
+// SetTemplateContext replaces the template with the ETag it was fetched with;
+// use UpdateTemplateContext to change a template in one call.
+func (p *Client) SetTemplateContext(ctx context.Context, template *TemplateConfig) error {
+	body, err := json.Marshal(template)
 	if err != nil {
 		return err
 	}
 
-	resp, err := p.goCDRequest("PATCH",
-		fmt.Sprintf("%s/go/api/agents/%s", p.host, agent.Uuid),
+	etag := p.etags.ifMatch(etagTemplate, template.Name, template.Etag)
+	if etag == "" {
+		return fmt.Errorf("%w: fetch template %s with GetTemplate or change it with UpdateTemplate", ErrNoEtag, template.Name)
+	}
+
+	accept, err := p.accept(ctx, apiTemplates)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "PUT",
+		fmt.Sprintf("%s/go/api/admin/templates/%s", p.host, template.Name),
 		body,
-		map[string]string{"Accept": "application/vnd.go.cd.v2+json",
-			"Content-Type": "application/json"})
+		map[string]string{"If-Match": etag,
+			"Content-Type": "application/json",
+			"Accept":       accept})
 
 	switch true {
 	case err != nil:
@@ -570,15 +2171,47 @@ func (p *Client) SetAgent(agent Agent) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
+		template.Etag = p.etags.update(etagTemplate, template.Name, resp)
 		return nil
 	}
 }
 
-func (p *Client) DeleteAgent(uuid string) error {
-	resp, err := p.goCDRequest("DELETE",
-		fmt.Sprintf("%s/go/api/agents/%s", p.host, uuid),
-		make([]byte, 0),
-		map[string]string{"Accept": "application/vnd.go.cd.v2+json"})
+func (p *Client) UpdateTemplate(name string, update func(*TemplateConfig) error) (*TemplateConfig, error) {
+	return p.UpdateTemplateContext(context.Background(), name, update)
+}
+
+func (p *Client) UpdateTemplateContext(ctx context.Context, name string, update func(*TemplateConfig) error) (*TemplateConfig, error) {
+	var template *TemplateConfig
+	err := p.retryConflict(func() error {
+		var err error
+		if template, err = p.GetTemplateContext(ctx, name); err != nil {
+			return err
+		}
+		if err := update(template); err != nil {
+			return err
+		}
+		return p.SetTemplateContext(ctx, template)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (p *Client) DeleteTemplate(name string) error {
+	return p.DeleteTemplateContext(context.Background(), name)
+}
+
+func (p *Client) DeleteTemplateContext(ctx context.Context, name string) error {
+	accept, err := p.accept(ctx, apiTemplates)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "DELETE",
+		fmt.Sprintf("%s/go/api/admin/templates/%s", p.host, name),
+		[]byte{},
+		map[string]string{"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -586,15 +2219,43 @@ func (p *Client) DeleteAgent(uuid string) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
+		p.etags.delete(etagTemplate, name)
 		return nil
 	}
 }
 
+func (p *Client) GetTemplatePipelines(name string) ([]string, error) {
+	return p.GetTemplatePipelinesContext(context.Background(), name)
+}
+
+// GetTemplatePipelinesContext lists the pipelines built from the template.
+func (p *Client) GetTemplatePipelinesContext(ctx context.Context, name string) ([]string, error) {
+	templates, err := p.GetTemplatesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range templates {
+		if template.Name == name {
+			return template.Pipelines, nil
+		}
+	}
+	return nil, fmt.Errorf("Template %s not found", name)
+}
+
 func (p *Client) GetAllUsers() ([]*User, error) {
-	resp, err := p.goCDRequest("GET",
+	return p.GetAllUsersContext(context.Background())
+}
+
+func (p *Client) GetAllUsersContext(ctx context.Context) ([]*User, error) {
+	accept, err := p.accept(ctx, apiUsers)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
 		fmt.Sprintf("%s/go/api/users", p.host),
 		make([]byte, 0),
-		map[string]string{"Accept": "application/vnd.go.cd.v1+json"})
+		map[string]string{"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -615,10 +2276,18 @@ func (p *Client) GetAllUsers() ([]*User, error) {
 }
 
 func (p *Client) GetUser(login string) (*User, error) {
-	resp, err := p.goCDRequest("GET",
+	return p.GetUserContext(context.Background(), login)
+}
+
+func (p *Client) GetUserContext(ctx context.Context, login string) (*User, error) {
+	accept, err := p.accept(ctx, apiUsers)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.goCDRequest(ctx, "GET",
 		fmt.Sprintf("%s/go/api/users/%s", p.host, login),
 		make([]byte, 0),
-		map[string]string{"Accept": "application/vnd.go.cd.v1+json"})
+		map[string]string{"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -632,15 +2301,23 @@ func (p *Client) GetUser(login string) (*User, error) {
 }
 
 func (p *Client) NewUser(user *User) error {
+	return p.NewUserContext(context.Background(), user)
+}
+
+func (p *Client) NewUserContext(ctx context.Context, user *User) error {
 	body, err := json.Marshal(user)
 	if err != nil {
 		return err
 	}
 
-	resp, err := p.goCDRequest("POST",
+	accept, err := p.accept(ctx, apiUsers)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "POST",
 		fmt.Sprintf("%s/go/api/users", p.host),
 		body,
-		map[string]string{"Accept": "application/vnd.go.cd.v1+json",
+		map[string]string{"Accept": accept,
 			"Content-Type": "application/json"})
 
 	switch true {
@@ -649,12 +2326,17 @@ func (p *Client) NewUser(user *User) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
 		return nil
 	}
 }
 
 func (p *Client) SetUser(user *User) error {
-	old_user, err := p.GetUser(user.LoginName)
+	return p.SetUserContext(context.Background(), user)
+}
+
+func (p *Client) SetUserContext(ctx context.Context, user *User) error {
+	old_user, err := p.GetUserContext(ctx, user.LoginName)
 	if err != nil {
 		return err
 	}
@@ -670,10 +2352,14 @@ func (p *Client) SetUser(user *User) error {
 		return err
 	}
 
-	resp, err := p.goCDRequest("PATCH",
+	accept, err := p.accept(ctx, apiUsers)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "PATCH",
 		fmt.Sprintf("%s/go/api/users/%s", p.host, user.LoginName),
 		body,
-		map[string]string{"Accept": "application/vnd.go.cd.v1+json",
+		map[string]string{"Accept": accept,
 			"Content-Type": "application/json"})
 
 	switch true {
@@ -682,15 +2368,24 @@ func (p *Client) SetUser(user *User) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
 		return nil
 	}
 }
 
 func (p *Client) DeleteUser(login string) error {
-	resp, err := p.goCDRequest("DELETE",
+	return p.DeleteUserContext(context.Background(), login)
+}
+
+func (p *Client) DeleteUserContext(ctx context.Context, login string) error {
+	accept, err := p.accept(ctx, apiUsers)
+	if err != nil {
+		return err
+	}
+	resp, err := p.goCDRequest(ctx, "DELETE",
 		fmt.Sprintf("%s/go/api/users/%s", p.host, login),
 		make([]byte, 0),
-		map[string]string{"Accept": "application/vnd.go.cd.v1+json"})
+		map[string]string{"Accept": accept})
 
 	switch true {
 	case err != nil:
@@ -698,16 +2393,21 @@ func (p *Client) DeleteUser(login string) error {
 	case resp.StatusCode != http.StatusOK:
 		return p.createError(resp)
 	default:
+		resp.Body.Close()
 		return nil
 	}
 }
 
 func (p *Client) FindPipelineConfig(name string) (*PipelineConfig, *Environment, error) {
-	pipeline, err := p.GetPipelineConfig(name)
+	return p.FindPipelineConfigContext(context.Background(), name)
+}
+
+func (p *Client) FindPipelineConfigContext(ctx context.Context, name string) (*PipelineConfig, *Environment, error) {
+	pipeline, err := p.GetPipelineConfigContext(ctx, name)
 	if err != nil {
 		return nil, nil, err
 	}
-	envs, err := p.GetEnvironments()
+	envs, err := p.GetEnvironmentsContext(ctx)
 	if err != nil {
 		return pipeline, nil, err
 	}
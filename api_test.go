@@ -1,12 +1,18 @@
 package gocd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -100,10 +106,10 @@ func TestClient_PausePipeline(t *testing.T) {
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
 			return
-		} else if strings.Compare(string(body), "pauseCause=take some rest") != 0 {
+		} else if strings.Compare(string(body), "pauseCause=take+some+rest") != 0 {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, fmt.Sprintf(`{"Error":"Body %s != pauseCause=take some rest"}`, body))
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"Body %s != pauseCause=take+some+rest"}`, body))
 			return
 		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -111,15 +117,51 @@ func TestClient_PausePipeline(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(server.URL, "", "")
-	if err := client.PausePipeline("pipeline"); err != nil {
+	client := New(server.URL, "", "", WithServerVersion("17.3.0"))
+	if err := client.PausePipeline("pipeline", "take some rest"); err != nil {
 		t.Error(err)
 		t.Fail()
 	}
 }
 
+func TestClient_PausePipelineJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Header.Get("Accept"), "application/vnd.go.cd.v1+json") != 0 ||
+			strings.Compare(r.Header.Get("X-GoCD-Confirm"), "true") != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["pause_cause"] != "deploy freeze" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"message":"Pipeline 'pipeline' paused successfully."}`)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("18.2.0"))
+	assert.NoError(t, client.PausePipeline("pipeline", "deploy freeze"))
+}
+
+func TestClient_IsPipelinePaused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"paused":true,"paused_cause":"deploy freeze","paused_by":"jdoe","locked":false,"schedulable":false}`)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("18.2.0"))
+	paused, by, cause, err := client.IsPipelinePaused("pipeline")
+	assert.NoError(t, err)
+	assert.True(t, paused)
+	assert.Equal(t, by, "jdoe")
+	assert.Equal(t, cause, "deploy freeze")
+}
+
 func TestClient_UnpausePipeline(t *testing.T) {
+	accepts := make([]string, 0)
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepts = append(accepts, r.Header.Get("Accept"))
 		if strings.Compare(r.Method, "POST") != 0 {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -138,11 +180,13 @@ func TestClient_UnpausePipeline(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(server.URL, "", "")
+	client := New(server.URL, "", "", WithServerVersion("17.3.0"))
 	if err := client.UnpausePipeline("pipeline"); err != nil {
 		t.Error(err)
 		t.Fail()
 	}
+	assert.NoError(t, New(server.URL, "", "", WithServerVersion("18.2.0")).UnpausePipeline("pipeline"))
+	assert.Equal(t, accepts, []string{"", "application/vnd.go.cd.v1+json"})
 }
 
 func TestClient_SchedulePipeline(t *testing.T) {
@@ -166,13 +210,55 @@ func TestClient_SchedulePipeline(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(server.URL, "", "")
-	if err := client.SchedulePipeline("pipeline", []byte{}); err != nil {
+	client := New(server.URL, "", "", WithServerVersion("17.3.0"))
+	if _, err := client.SchedulePipeline("pipeline", nil); err != nil {
 		t.Error(err)
 		t.Fail()
 	}
 }
 
+func TestClient_SchedulePipelineOptions(t *testing.T) {
+	bodies := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Accept") == "" {
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, "Request to schedule pipeline pipeline accepted")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"message":"Request to schedule pipeline 'pipeline' accepted","counter":42}`)
+	}))
+	defer server.Close()
+
+	options := NewScheduleOptions()
+	options.Materials["cf0b2a3e"] = "123abc"
+	options.Variables["ENV"] = "staging"
+	options.SecureVariables["TOKEN"] = "secret"
+	options.SkipMaterialUpdate = true
+
+	counter, err := New(server.URL, "", "", WithServerVersion("17.3.0")).SchedulePipeline("pipeline", options)
+	assert.NoError(t, err)
+	assert.Equal(t, counter, 0)
+	counter, err = New(server.URL, "", "", WithServerVersion("18.2.0")).SchedulePipeline("pipeline", options)
+	assert.NoError(t, err)
+	assert.Equal(t, counter, 42)
+
+	assert.Equal(t, bodies[0], "material_fingerprint%5Bcf0b2a3e%5D=123abc&secure_variables%5BTOKEN%5D=secret&variables%5BENV%5D=staging")
+	assert.JSONEq(t, bodies[1], `{
+		"materials": [{"fingerprint": "cf0b2a3e", "revision": "123abc"}],
+		"environment_variables": [
+			{"name": "ENV", "secure": false, "value": "staging"},
+			{"name": "TOKEN", "secure": true, "value": "secret"}],
+		"update_materials_before_scheduling": false}`)
+
+	_, err = New(server.URL, "", "", WithServerVersion("18.2.0")).SchedulePipeline("pipeline", &ScheduleOptions{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, bodies[2], `{"update_materials_before_scheduling": true}`)
+}
+
 func TestClient_GetGroups(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.Compare(r.Method, "GET") != 0 {
@@ -404,6 +490,1029 @@ func TestClient_GetUser(t *testing.T) {
 	}
 }
 
+func TestClient_GetPipelineConfigContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := New(server.URL, "", "")
+	pipeline, err := client.GetPipelineConfigContext(ctx, "my_pipeline")
+	assert.Nil(t, pipeline)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestClient_DeletePipelineConfigContext(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := New(server.URL, "", "")
+	err := client.DeletePipelineConfigContext(ctx, "my_pipeline")
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, requests, 0)
+}
+
+func TestClient_GetAgentJobHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Method, "GET") != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"method %s != GET"}`, r.Method))
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_agent_job_history"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if history, err := client.GetAgentJobHistory("adb9540a-b954-4571-9d9b-2f330739d4da", 0); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(history.Jobs), 2)
+		assert.Equal(t, history.Jobs[0].Name, "upload")
+		assert.Equal(t, history.Pagination.Total, 3)
+	}
+}
+
+func TestClient_IterateAgentJobHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go/api/agents/uuid/job_run_history/0":
+			data, _ := ioutil.ReadFile(createPath("get_agent_job_history"))
+			w.Write(data)
+		case "/go/api/agents/uuid/job_run_history/2":
+			fmt.Fprint(w, `{"jobs":[{"name":"test","id":1}],"pagination":{"offset":2,"total":3,"page_size":2}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	names := make([]string, 0)
+	it := client.IterateAgentJobHistory("uuid")
+	for it.Next() {
+		names = append(names, it.Job().Name)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, names, []string{"upload", "build", "test"})
+}
+
+func TestClient_GetAllMaterials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Method, "GET") != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"method %s != GET"}`, r.Method))
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_materials"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if materials, err := client.GetAllMaterials(); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(materials), 2)
+		assert.Equal(t, materials[0].Type, "Git")
+	}
+}
+
+func TestClient_GetMaterialModifications(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/materials/2d05446c/modifications/0") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_material_modifications"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if mods, err := client.GetMaterialModifications("2d05446c", 0); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(mods.Modifications), 2)
+		assert.Equal(t, mods.Modifications[0].Revision, "a788f1876e2e1f6e5a1e91006e75cd1d467a0edb")
+		assert.Equal(t, mods.Pagination.Total, 2)
+	}
+}
+
+func TestClient_NotifyGitMaterial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Method, "POST") != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"method %s != POST"}`, r.Method))
+			return
+		}
+		if strings.Compare(r.URL.Path, "/go/api/material/notify/git") != 0 ||
+			strings.Compare(r.FormValue("repository_url"), "https://github.com/gocd/gocd.git") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, "The material is now scheduled for an update. Please check relevant pipeline(s) for status.")
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.NotifyGitMaterial("https://github.com/gocd/gocd.git"))
+	assert.True(t, IsNotFound(client.NotifyGitMaterial("https://github.com/gocd/other.git")))
+}
+
+func TestClient_NotifySVNMaterial(t *testing.T) {
+	forms := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/material/notify/svn") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.ParseForm()
+		forms = append(forms, r.PostForm.Encode())
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.NotifySVNMaterial("4a8a3d5f-59e0-4f2a-a7d4-0f5d2c9b2a11"))
+	assert.NoError(t, client.NotifySVNMaterialURL("https://svn.example.com/repo/trunk"))
+	assert.Equal(t, forms, []string{"uuid=4a8a3d5f-59e0-4f2a-a7d4-0f5d2c9b2a11",
+		"repository_url=https%3A%2F%2Fsvn.example.com%2Frepo%2Ftrunk"})
+}
+
+func TestClient_CreateBackup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Method, "POST") != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"method %s != POST"}`, r.Method))
+			return
+		}
+		if strings.Compare(r.Header.Get("Confirm"), "true") != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, fmt.Sprint(`{"Error":"header Confirm != true"}`))
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("post_backup"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("16.7.0"))
+	if backup, err := client.CreateBackup(); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, backup.Path, "/var/lib/go-server/serverBackups/backup_20150807-153719")
+		assert.Equal(t, backup.User.LoginName, "admin")
+		assert.Equal(t, backup.Time, time.Date(2015, 8, 7, 10, 7, 19, 868000000, time.UTC))
+		assert.True(t, backup.Done())
+	}
+
+	_, err := client.GetBackup("1")
+	assert.True(t, errors.Is(err, ErrUnsupportedByServer))
+}
+
+func TestClient_CreateBackupAndWait(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch true {
+		case r.Method == "POST" && r.Header.Get("X-GoCD-Confirm") == "true":
+			w.Header().Set("Location", "/go/api/backups/1")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == "GET" && r.URL.Path == "/go/api/backups/1":
+			polls++
+			if polls == 1 {
+				fmt.Fprint(w, `{"status":"IN_PROGRESS","progress_status":"BACKUP_DATABASE"}`)
+				return
+			}
+			data, _ := ioutil.ReadFile(createPath("get_backup"))
+			w.Write(data)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("19.3.0"))
+	if backup, err := client.CreateBackupAndWait(time.Millisecond); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, polls, 2)
+		assert.Equal(t, backup.ID, "1")
+		assert.Equal(t, backup.Status, BackupCompleted)
+		assert.Equal(t, backup.Path, "/var/lib/go-server/serverBackups/backup_20190305-175737")
+	}
+}
+
+func TestClient_WaitForBackupError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"ERROR","message":"Disk full"}`)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("19.3.0"))
+	backup, err := client.WaitForBackup("1", time.Millisecond)
+	assert.Error(t, err)
+	assert.Equal(t, backup.Message, "Disk full")
+}
+
+func TestClient_WaitForBackupContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"IN_PROGRESS"}`)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := New(server.URL, "", "", WithServerVersion("19.3.0"))
+	_, err := client.WaitForBackupContext(ctx, "1", 10*time.Millisecond)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestClient_ListArtifacts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/files/foo/1/bar/1/baz.json") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_artifacts"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if artifacts, err := client.ListArtifacts("foo", 1, "bar", 1, "baz"); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(artifacts), 2)
+		assert.True(t, artifacts[0].IsFolder())
+		assert.Equal(t, artifacts[0].Files[0].Name, "console.log")
+		assert.False(t, artifacts[1].IsFolder())
+	}
+}
+
+func TestClient_DownloadArtifactFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.EscapedPath(), "/go/files/foo/1/bar/1/baz/cruise-output/my%20console.log") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "Build successful")
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	buf := bytes.Buffer{}
+	assert.NoError(t, client.DownloadArtifactFile("foo", 1, "bar", 1, "baz", "cruise-output/my console.log", &buf))
+	assert.Equal(t, buf.String(), "Build successful")
+	assert.True(t, IsNotFound(client.DownloadArtifactFile("foo", 1, "bar", 1, "baz", "missing.log", &buf)))
+}
+
+func TestClient_DownloadArtifactDirectory(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/files/foo/1/bar/1/baz/cruise-output.zip") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		fmt.Fprint(w, "PK")
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	buf := bytes.Buffer{}
+	assert.NoError(t, client.DownloadArtifactDirectory("foo", 1, "bar", 1, "baz", "cruise-output", &buf))
+	assert.Equal(t, requests, 2)
+	assert.Equal(t, buf.String(), "PK")
+}
+
+func TestClient_DownloadArtifactDirectoryGivesUp(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	defer func(wait time.Duration) { artifactMaxWait = wait }(artifactMaxWait)
+	artifactMaxWait = 1500 * time.Millisecond
+
+	client := New(server.URL, "", "")
+	err := client.DownloadArtifactDirectory("foo", 1, "bar", 1, "baz", "cruise-output", &bytes.Buffer{})
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "still being zipped"), err.Error())
+	assert.Equal(t, requests, 2)
+}
+
+func TestClient_GetPipelineStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/pipelines/pipeline/status") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_pipeline_status"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if status, err := client.GetPipelineStatus("pipeline"); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, *status, PipelineStatus{
+			Paused:      true,
+			PausedCause: "Reason for pausing this pipeline",
+			PausedBy:    "admin",
+			Locked:      true,
+			Schedulable: false})
+	}
+
+	status := PipelineStatus{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"paused":true,"paused_cause":"deploy freeze","paused_by":"jdoe"}`), &status))
+	assert.Equal(t, status.PausedCause, "deploy freeze")
+	assert.Equal(t, status.PausedBy, "jdoe")
+}
+
+func TestClient_ReleasePipelineLock(t *testing.T) {
+	accepts, paths := make([]string, 0), make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Method, "POST") != 0 {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if strings.Compare(r.Header.Get("Confirm"), "true") != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		accepts = append(accepts, r.Header.Get("Accept"))
+		paths = append(paths, r.URL.Path)
+		fmt.Fprint(w, "pipeline lock released for pipeline")
+	}))
+	defer server.Close()
+
+	assert.NoError(t, New(server.URL, "", "", WithServerVersion("17.3.0")).ReleasePipelineLock("pipeline"))
+	assert.NoError(t, New(server.URL, "", "", WithServerVersion("18.2.0")).ReleasePipelineLock("pipeline"))
+	assert.Equal(t, accepts, []string{"", "application/vnd.go.cd.v1+json"})
+	assert.Equal(t, paths, []string{"/go/api/pipelines/pipeline/releaseLock", "/go/api/pipelines/pipeline/unlock"})
+}
+
+func TestClient_GetScheduledJobs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/jobs/scheduled.xml") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile("./test_data/get_scheduled_jobs.xml")
+		if err != nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if jobs, err := client.GetScheduledJobs(); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(jobs), 2)
+		assert.Equal(t, jobs[0].Name, "job1")
+		assert.Equal(t, jobs[0].ID, 6)
+		assert.Equal(t, jobs[0].BuildLocator, "mypipeline/5/defaultStage/1/job1")
+		assert.Equal(t, jobs[0].Environment, "sample_environment")
+		assert.Equal(t, jobs[0].Resources, []string{"linux", "docker"})
+		assert.Equal(t, jobs[0].EnvironmentVariables, []ScheduledJobVariable{{Name: "JAVA_HOME", Value: "/usr/lib/jvm/java-8-openjdk"}})
+
+		byResource := ScheduledJobsByResource(jobs)
+		assert.Equal(t, len(byResource["linux"]), 2)
+		assert.Equal(t, len(byResource["docker"]), 1)
+	}
+}
+
+func TestClient_GetJobHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/jobs/mypipeline/defaultStage/test/history/0") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_job_history"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if history, err := client.GetJobHistory("mypipeline", "defaultStage", "test", 0); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(history.Jobs), 2)
+		assert.Equal(t, history.Jobs[1].Result, "Failed")
+		assert.Equal(t, history.Pagination.Total, 2)
+	}
+
+	results := make([]string, 0)
+	it := client.IterateJobHistory("mypipeline", "defaultStage", "test")
+	for it.Next() {
+		results = append(results, it.Job().Result)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, results, []string{"Passed", "Failed"})
+}
+
+func TestClient_GetJobProperties(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go/properties/foo/1/bar/1/baz":
+			fmt.Fprint(w, "cruise_agent,cruise_job_result,test_duration\nagent-1,Passed,12.5\n")
+		case "/go/properties/foo/1/bar/1/baz/test_duration":
+			fmt.Fprint(w, "test_duration\n12.5\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	properties, err := client.GetJobProperties("foo", 1, "bar", 1, "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, properties, JobProperties{"cruise_agent": "agent-1", "cruise_job_result": "Passed", "test_duration": "12.5"})
+	duration, err := properties.Float("test_duration")
+	assert.NoError(t, err)
+	assert.Equal(t, duration, 12.5)
+
+	value, err := client.GetJobProperty("foo", 1, "bar", 1, "baz", "test_duration")
+	assert.NoError(t, err)
+	assert.Equal(t, value, "12.5")
+	_, err = client.GetJobProperty("foo", 1, "bar", 1, "baz", "missing")
+	assert.True(t, IsNotFound(err))
+}
+
+func TestClient_GetHistoricalJobProperties(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if strings.Compare(r.URL.Path, "/go/properties/search") != 0 ||
+			query.Get("pipelineName") != "foo" || query.Get("stageName") != "bar" || query.Get("jobName") != "baz" ||
+			query.Get("limitCount") != "2" || query.Get("limitPipeline") != "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile("./test_data/get_historical_properties.csv")
+		if err != nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if history, err := client.GetHistoricalJobProperties("foo", "bar", "baz", "", 2); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(history), 2)
+		assert.Equal(t, history[1].PipelineCounter, 42)
+		assert.Equal(t, history[1].PipelineLabel, "42")
+		assert.Equal(t, history[1].StageCounter, 2)
+		assert.Equal(t, history[1].Properties["test_duration"], "13.75")
+	}
+}
+
+func TestClient_CreateJobProperty(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Method, "POST") != 0 {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if strings.Compare(r.URL.Path, "/go/properties/foo/1/bar/1/baz/test_duration") != 0 ||
+			strings.Compare(r.Header.Get("Confirm"), "true") != 0 ||
+			r.PostFormValue("value") != "12.5" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "Property 'test_duration' created with value '12.5'")
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.CreateJobProperty("foo", 1, "bar", 1, "baz", "test_duration", "12.5"))
+}
+
+func TestClient_GetConfigModifications(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/config/revisions") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_config_revisions"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	if modifications, err := client.GetConfigModifications(); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(modifications), 2)
+		assert.Equal(t, modifications[1].Username, "jdoe")
+		assert.Equal(t, modifications[1].MD5, "a6f8d14d3bdf1e6a2bbf0a1c1ab0e56e")
+		assert.Equal(t, modifications[1].Time, 1470899896812)
+		assert.Equal(t, modifications[1].CommitSHA, "b1c0f3bbd3d0ee2a97bd6a2b5e4ad3e19b1f6bd2")
+	}
+}
+
+func TestClient_GetConfigDiff(t *testing.T) {
+	diff := "--- a/cruise-config.xml\n+++ b/cruise-config.xml\n@@ -1 +1 @@\n-<pipeline name=\"a\">\n+<pipeline name=\"b\">\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/config/diff/b1c0f3bb/0fa6bba5") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, diff)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	result, err := client.GetConfigDiff("b1c0f3bb", "0fa6bba5")
+	assert.NoError(t, err)
+	assert.Equal(t, result, diff)
+}
+
+func TestClient_GetConfigXML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/admin/config.xml") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Header().Set("X-CRUISE-CONFIG-MD5", "3a1e3b9f3e8f6c6c8b0e9a8a0a6b1d52")
+		fmt.Fprint(w, `<cruise schemaVersion="85"></cruise>`)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	config, md5, err := client.GetConfigXML()
+	assert.NoError(t, err)
+	assert.Equal(t, string(config), `<cruise schemaVersion="85"></cruise>`)
+	assert.Equal(t, md5, "3a1e3b9f3e8f6c6c8b0e9a8a0a6b1d52")
+}
+
+func TestClient_GetDashboard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/dashboard") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_dashboard"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("16.7.0"))
+	if dashboard, err := client.GetDashboard(); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(dashboard.Groups), 2)
+		assert.Equal(t, dashboard.Groups[0].Group.Name, "first")
+		assert.True(t, dashboard.Groups[0].Group.Exist("up42"))
+		assert.Equal(t, len(dashboard.Groups[1].Pipelines), 0)
+
+		pipeline := dashboard.Pipeline("up42")
+		assert.Equal(t, pipeline.Label, "${COUNT}")
+		assert.Equal(t, pipeline.PauseInfo, PipelinePauseInfo{Paused: true, PausedBy: "admin", PauseReason: "deploy freeze"})
+		assert.Equal(t, len(pipeline.Instances), 1)
+		assert.Equal(t, pipeline.Instances[0].Name, "up42")
+		assert.Equal(t, pipeline.Instances[0].Label, "2")
+		assert.Equal(t, pipeline.Instances[0].TriggeredBy, "changes")
+		assert.Equal(t, len(pipeline.Instances[0].Stages), 2)
+		assert.Equal(t, pipeline.Instances[0].Stages[0].Name, "up42_stage")
+		assert.Equal(t, pipeline.Instances[0].Stages[0].Status, "Building")
+		assert.Nil(t, dashboard.Pipeline("missing"))
+	}
+}
+
+func TestClient_GetTemplates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/admin/templates") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_templates"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("16.10.0"))
+	if templates, err := client.GetTemplates(); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, len(templates), 2)
+		assert.Equal(t, *templates[0], Template{Name: "build-and-test", Pipelines: []string{"up42", "down42"}})
+		assert.Equal(t, len(templates[1].Pipelines), 0)
+	}
+
+	pipelines, err := client.GetTemplatePipelines("build-and-test")
+	assert.NoError(t, err)
+	assert.Equal(t, pipelines, []string{"up42", "down42"})
+	_, err = client.GetTemplatePipelines("missing")
+	assert.Error(t, err)
+}
+
+func TestClient_GetTemplate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/admin/templates/build-and-test") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_template"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Header().Set("Etag", "123456789")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("16.10.0"))
+	if template, err := client.GetTemplate("build-and-test"); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, template.Etag, "123456789")
+		assert.Equal(t, template.Name, "build-and-test")
+		assert.Equal(t, len(template.Stages), 1)
+		assert.Equal(t, template.Stages[0].Jobs[0].Name, "compile")
+		assert.Equal(t, template.Stages[0].Jobs[0].Resources, []string{"linux"})
+	}
+}
+
+func TestClient_NewTemplate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Method, "POST") != 0 {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		template := NewTemplateConfig()
+		if err := json.NewDecoder(r.Body).Decode(template); err != nil || template.Name != "deploy" || len(template.Stages) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Etag", "123456789")
+		json.NewEncoder(w).Encode(template)
+	}))
+	defer server.Close()
+
+	template := NewTemplateConfig()
+	template.Name = "deploy"
+	template.AddStage(StageConfig{Name: "deploy", Jobs: []JobConfig{{Name: "deploy"}}})
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.NewTemplate(template))
+	assert.Equal(t, template.Etag, "123456789")
+}
+
+func TestClient_SetTemplate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			if r.Header.Get("If-Match") != "123456789" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			w.Header().Set("Etag", "987654321")
+		case "DELETE":
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	template := NewTemplateConfig()
+	template.Name = "deploy"
+	template.Etag = "123456789"
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.SetTemplate(template))
+	assert.Equal(t, template.Etag, "987654321")
+	assert.True(t, IsConflict(client.SetTemplate(template)))
+	assert.NoError(t, client.DeleteTemplate("deploy"))
+
+	unfetched := NewTemplateConfig()
+	unfetched.Name = "deploy"
+	assert.True(t, errors.Is(client.SetTemplate(unfetched), ErrNoEtag))
+}
+
+func TestClient_GetPipelineGroup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.URL.Path, "/go/api/admin/pipeline_groups/first") != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := ioutil.ReadFile(createPath("get_pipeline_group"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			fmt.Fprint(w, fmt.Sprintf(`{"Error":"%v"}`, err))
+			return
+		}
+		w.Header().Set("Etag", "123456789")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("18.6.0"))
+	if group, err := client.GetPipelineGroup("first"); err != nil {
+		t.Error(err)
+		t.Fail()
+	} else {
+		assert.Equal(t, group.Etag, "123456789")
+		assert.Equal(t, group.Authorization.Admins.Users, []string{"admin"})
+		assert.Equal(t, group.Authorization.Operate.Roles, []string{"deployers"})
+		assert.Equal(t, len(group.Pipelines), 1)
+		assert.Equal(t, group.Pipelines[0].Name, "up42")
+	}
+}
+
+func TestClient_PipelineGroupAdministration(t *testing.T) {
+	bodies := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Compare(r.Header.Get("Accept"), "application/vnd.go.cd.v1+json") != 0 {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		body := make(map[string]interface{})
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies[r.Method] = body
+		switch r.Method + " " + r.URL.Path {
+		case "POST /go/api/admin/pipeline_groups":
+			w.Header().Set("Etag", "1")
+		case "GET /go/api/admin/pipeline_groups/team":
+			w.Header().Set("Etag", "1")
+			fmt.Fprint(w, `{"name":"team","authorization":{"view":{"users":[],"roles":[]},"operate":{"users":[],"roles":[]},"admins":{"users":[],"roles":[]}},"pipelines":[]}`)
+		case "PUT /go/api/admin/pipeline_groups/team":
+			if r.Header.Get("If-Match") != "1" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			w.Header().Set("Etag", "2")
+		case "DELETE /go/api/admin/pipeline_groups/team":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("18.6.0"))
+	group := NewPipelineGroupConfig("team")
+	assert.NoError(t, client.NewPipelineGroup(group))
+	assert.Equal(t, bodies["POST"]["name"], "team")
+
+	authorization := NewGroupAuthorization()
+	authorization.Operate.Roles = append(authorization.Operate.Roles, "developers")
+	assert.NoError(t, client.SetPipelineGroupAuthorization("team", authorization))
+	operate := bodies["PUT"]["authorization"].(map[string]interface{})["operate"].(map[string]interface{})
+	assert.Equal(t, operate["roles"], []interface{}{"developers"})
+
+	assert.NoError(t, client.DeletePipelineGroup("team"))
+	assert.True(t, errors.Is(client.SetPipelineGroup(NewPipelineGroupConfig("team")), ErrNoEtag))
+	assert.True(t, errors.Is(New(server.URL, "", "", WithServerVersion("18.1.0")).DeletePipelineGroup("team"), ErrUnsupportedByServer))
+}
+
+// configXMLServer serves cruise-config.xml and accepts edits guarded by its
+// md5. Every one of the first conflicts edits sneaks in another change first.
+type configXMLServer struct {
+	mu        sync.Mutex
+	config    string
+	conflicts int
+	posts     int
+}
+
+func newConfigXMLServer(s *configXMLServer) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.URL.Path != "/go/api/admin/config.xml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			w.Header().Set("X-CRUISE-CONFIG-MD5", md5Hex(s.config))
+			fmt.Fprint(w, s.config)
+		case "POST":
+			s.posts++
+			if s.conflicts > 0 {
+				s.conflicts--
+				s.config = strings.Replace(s.config, "</cruise>", "<!-- edited -->\n</cruise>", 1)
+			}
+			if r.Header.Get("Confirm") != "true" || r.FormValue("md5") != md5Hex(s.config) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			s.config = r.FormValue("xmlFile")
+		}
+	}))
+}
+
+const cruiseConfig = `<?xml version="1.0" encoding="utf-8"?>
+<cruise xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" schemaVersion="108">
+  <pipelines group="first">
+    <authorization>
+      <view>
+        <user>operate</user>
+      </view>
+    </authorization>
+    <pipeline name="build">
+      <materials>
+        <git url="https://github.com/gocd/gocd.git" />
+      </materials>
+      <stage name="package">
+        <jobs>
+          <job name="jar">
+            <tasks>
+              <exec command="make" />
+            </tasks>
+          </job>
+        </jobs>
+      </stage>
+    </pipeline>
+    <pipeline name="deploy">
+      <timer onlyOnChanges="true">0 0 22 ? * MON-FRI</timer>
+      <materials>
+        <pipeline pipelineName="build" stageName="package" />
+      </materials>
+      <stage name="production">
+        <jobs>
+          <job name="rollout" elasticProfileId="docker-small">
+            <tasks>
+              <exec command="./rollout.sh" />
+            </tasks>
+            <tabs>
+              <tab name="report" path="reports/rollout.html" />
+            </tabs>
+          </job>
+        </jobs>
+      </stage>
+    </pipeline>
+  </pipelines>
+  <pipelines group="second" />
+  <environments>
+    <environment name="env">
+      <pipelines>
+        <pipeline name="deploy" />
+      </pipelines>
+    </environment>
+  </environments>
+</cruise>
+`
+
+func TestClient_MovePipelineToGroup(t *testing.T) {
+	store := &configXMLServer{config: cruiseConfig, conflicts: 1}
+	server := newConfigXMLServer(store)
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.MovePipelineToGroup("build", "second"))
+	assert.Equal(t, store.posts, 2)
+
+	build := cruiseConfig[strings.Index(cruiseConfig, "    <pipeline name=\"build\">"):strings.Index(cruiseConfig, "    <pipeline name=\"deploy\">")]
+	expected := strings.Replace(cruiseConfig, build, "", 1)
+	expected = strings.Replace(expected, `  <pipelines group="second" />`+"\n",
+		`  <pipelines group="second">`+"\n"+build+"  </pipelines>\n", 1)
+	expected = strings.Replace(expected, "</cruise>", "<!-- edited -->\n</cruise>", 1)
+	assert.Equal(t, store.config, expected)
+
+	assert.NoError(t, client.MovePipelineToGroup("deploy", "second"))
+	deploy := cruiseConfig[strings.Index(cruiseConfig, "    <pipeline name=\"deploy\">"):strings.Index(cruiseConfig, "  </pipelines>\n")]
+	expected = strings.Replace(expected, deploy, "", 1)
+	expected = strings.Replace(expected, build+"  </pipelines>", build+deploy+"  </pipelines>", 1)
+	assert.Equal(t, store.config, expected)
+
+	posts := store.posts
+	assert.NoError(t, client.MovePipelineToGroup("deploy", "second"))
+	assert.Error(t, client.MovePipelineToGroup("deploy", "missing"))
+	assert.Error(t, client.MovePipelineToGroup("missing", "first"))
+	assert.Equal(t, store.posts, posts)
+	assert.Equal(t, store.config, expected)
+}
+
+func TestClient_RenamePipelineGroup(t *testing.T) {
+	store := &configXMLServer{config: cruiseConfig}
+	server := newConfigXMLServer(store)
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.RenamePipelineGroup("first", "a&b"))
+	assert.Equal(t, store.config, strings.Replace(cruiseConfig, `<pipelines group="first">`, `<pipelines group="a&amp;b">`, 1))
+
+	assert.Error(t, client.RenamePipelineGroup("a&b", "second"))
+	assert.Error(t, client.RenamePipelineGroup("missing", "third"))
+	assert.Equal(t, store.posts, 1)
+}
+
 //func TestClient_SetPipelineConfig(t *testing.T) {
 //	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 //		if strings.Compare(r.Method, "PUT") != 0 {
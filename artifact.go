@@ -0,0 +1,266 @@
+package gocd
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ArtifactFile   = "file"
+	ArtifactFolder = "folder"
+)
+
+// artifactPollInterval is how long to wait for the server to finish zipping
+// a directory when it does not send Retry-After.
+var artifactPollInterval = time.Second
+
+// artifactMaxWait bounds the wait for a zipped directory when the caller's
+// context has no deadline.
+var artifactMaxWait = 10 * time.Minute
+
+// WithUploadVerification makes UploadArtifact download every file it
+// uploaded and compare its md5 with the local one, at the cost of reading the
+// upload back.
+func WithUploadVerification() Option {
+	return func(p *Client) {
+		p.verifyUploads = true
+	}
+}
+
+// ProgressFunc is called as an upload proceeds with the bytes sent so far
+// and the total, which is -1 when it is not known up front.
+type ProgressFunc func(sent, total int64)
+
+// Artifact is a file or a folder of the artifacts of a job; folders list
+// their content in Files.
+type Artifact struct {
+	Name  string      `json:"name"`
+	URL   string      `json:"url"`
+	Type  string      `json:"type"`
+	Files []*Artifact `json:"files,omitempty"`
+}
+
+func (p *Artifact) IsFolder() bool {
+	return p.Type == ArtifactFolder
+}
+
+func artifactURL(host, pipeline string, pCounter int, stage string, sCounter int, job string, path string) string {
+	result := fmt.Sprintf("%s/go/files/%s/%d/%s/%d/%s", host,
+		url.PathEscape(pipeline), pCounter, url.PathEscape(stage), sCounter, url.PathEscape(job))
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment != "" {
+			result += "/" + url.PathEscape(segment)
+		}
+	}
+	return result
+}
+
+// extractZip unpacks the archive into dir, refusing entries that would be
+// written outside of it.
+func extractZip(archive *zip.Reader, dir string) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range archive.File {
+		target := filepath.Join(root, filepath.FromSlash(file.Name))
+		if target != root && !strings.HasPrefix(target, root+string(os.PathSeparator)) {
+			return fmt.Errorf("Artifact %s is outside of %s", file.Name, dir)
+		}
+
+		mode := file.Mode()
+		switch true {
+		case mode&os.ModeSymlink != 0:
+			return fmt.Errorf("Artifact %s is a symlink", file.Name)
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractZipFile(file, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(file *zip.File, target string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	perm := file.Mode().Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// progressReader reports every read to fn.
+type progressReader struct {
+	r     io.Reader
+	sent  int64
+	total int64
+	fn    ProgressFunc
+}
+
+func newProgressReader(r io.Reader, total int64, fn ProgressFunc) io.Reader {
+	if fn == nil {
+		return r
+	}
+	return &progressReader{r: r, total: total, fn: fn}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.fn(p.sent, p.total)
+	}
+	return n, err
+}
+
+// artifactUpload is a multipart body carrying one file, along with the md5
+// of every artifact it creates.
+type artifactUpload struct {
+	body        io.Reader
+	size        int64
+	contentType string
+	checksums   map[string]string
+}
+
+// newArtifactUpload wraps content in a multipart body under field, preceded
+// by a file_checksum part so the server records checksums. The body is
+// assembled from readers so the file is streamed rather than buffered.
+func newArtifactUpload(field, name string, content io.Reader, size int64, checksums map[string]string, progress ProgressFunc) (*artifactUpload, error) {
+	buf := bytes.Buffer{}
+	form := multipart.NewWriter(&buf)
+	part, err := form.CreateFormFile("file_checksum", "md5.checksum")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(formatChecksums(checksums)); err != nil {
+		return nil, err
+	}
+	if _, err := form.CreateFormFile(field, name); err != nil {
+		return nil, err
+	}
+	head := append([]byte{}, buf.Bytes()...)
+	buf.Reset()
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+	tail := buf.Bytes()
+
+	total := int64(len(head)) + size + int64(len(tail))
+	body := io.MultiReader(bytes.NewReader(head), content, bytes.NewReader(tail))
+	return &artifactUpload{
+		body:        newProgressReader(body, total, progress),
+		size:        total,
+		contentType: form.FormDataContentType(),
+		checksums:   checksums}, nil
+}
+
+func fileChecksum(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// zipDirectory writes the files below dir to w and returns their md5 keyed
+// by their path below dest.
+func zipDirectory(dir, dest string, w io.Writer) (map[string]string, error) {
+	checksums := make(map[string]string)
+	archive := zip.NewWriter(w)
+	err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || name == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			_, err := archive.Create(rel + "/")
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name, header.Method = rel, zip.Deflate
+		dst, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		hash := md5.New()
+		if _, err := io.Copy(io.MultiWriter(dst, hash), src); err != nil {
+			return err
+		}
+		checksums[path.Join(dest, rel)] = hex.EncodeToString(hash.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checksums, archive.Close()
+}
+
+// formatChecksums writes checksums as a Java properties file, the format of
+// md5.checksum.
+func formatChecksums(checksums map[string]string) []byte {
+	buf := bytes.Buffer{}
+	for _, key := range sortedKeys(checksums) {
+		for _, r := range key {
+			if strings.ContainsRune(`\=: #!`, r) {
+				buf.WriteByte('\\')
+			}
+			buf.WriteRune(r)
+		}
+		fmt.Fprintf(&buf, "=%s\n", checksums[key])
+	}
+	return buf.Bytes()
+}
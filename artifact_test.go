@@ -0,0 +1,222 @@
+package gocd
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createZip(t *testing.T, files map[string]string) []byte {
+	buf := bytes.Buffer{}
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newZipServer(data []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(data)
+	}))
+}
+
+func TestClient_ExtractArtifactDirectory(t *testing.T) {
+	server := newZipServer(createZip(t, map[string]string{
+		"cruise-output/":            "",
+		"cruise-output/console.log": "Build successful",
+		"cruise-output/sub/a.txt":   "a"}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "gocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.ExtractArtifactDirectory("foo", 1, "bar", 1, "baz", "cruise-output", dir))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "cruise-output", "console.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "Build successful")
+	data, err = ioutil.ReadFile(filepath.Join(dir, "cruise-output", "sub", "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "a")
+}
+
+func TestClient_ExtractArtifactDirectoryTraversal(t *testing.T) {
+	server := newZipServer(createZip(t, map[string]string{"../evil.txt": "evil"}))
+	defer server.Close()
+
+	parent, err := ioutil.TempDir("", "gocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(parent)
+	dir := filepath.Join(parent, "dest")
+
+	client := New(server.URL, "", "")
+	assert.Error(t, client.ExtractArtifactDirectory("foo", 1, "bar", 1, "baz", "cruise-output", dir))
+	_, err = os.Stat(filepath.Join(parent, "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// artifactStore mimics the artifact upload endpoints of a job, unpacking
+// zipfile uploads and recording the checksums it is sent. A corrupt store
+// truncates the files it is sent.
+type artifactStore struct {
+	mu        sync.Mutex
+	files     map[string]string
+	checksums []byte
+	corrupt   bool
+}
+
+func newArtifactServer(store *artifactStore) *httptest.Server {
+	prefix := "/go/files/foo/1/bar/1/baz/"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		switch true {
+		case r.Method == "GET":
+			data, ok := store.files[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(data))
+		case r.Method == "PUT" && r.Header.Get("Confirm") == "true":
+			data, _ := ioutil.ReadAll(r.Body)
+			store.files[name] += string(data)
+		case r.Method == "POST" && r.Header.Get("Confirm") == "true":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if checksum, _, err := r.FormFile("file_checksum"); err == nil {
+				data, _ := ioutil.ReadAll(checksum)
+				store.checksums = append(store.checksums, data...)
+			}
+			if file, _, err := r.FormFile("file"); err == nil {
+				data, _ := ioutil.ReadAll(file)
+				if store.corrupt {
+					data = data[:len(data)/2]
+				}
+				store.files[name] = string(data)
+			} else if file, header, err := r.FormFile("zipfile"); err == nil {
+				archive, _ := zip.NewReader(file, header.Size)
+				for _, f := range archive.File {
+					if !f.FileInfo().IsDir() {
+						src, _ := f.Open()
+						data, _ := ioutil.ReadAll(src)
+						store.files[path.Join(name, f.Name)] = string(data)
+					}
+				}
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func md5Hex(data string) string {
+	sum := md5.Sum([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestClient_UploadArtifactFile(t *testing.T) {
+	store := &artifactStore{files: make(map[string]string)}
+	server := newArtifactServer(store)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "gocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "results.xml")
+	ioutil.WriteFile(src, []byte("<testsuite/>"), 0644)
+
+	var sent, total int64
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.UploadArtifact("foo", 1, "bar", 1, "baz", "reports/results.xml", src, func(s, t int64) {
+		sent, total = s, t
+	}))
+	assert.Equal(t, store.files["reports/results.xml"], "<testsuite/>")
+	assert.Equal(t, sent, total)
+	assert.True(t, total > int64(len("<testsuite/>")))
+
+	store.corrupt = true
+	assert.NoError(t, client.UploadArtifact("foo", 1, "bar", 1, "baz", "other.xml", src, nil))
+	err = New(server.URL, "", "", WithUploadVerification()).UploadArtifact("foo", 1, "bar", 1, "baz", "other.xml", src, nil)
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "other.xml"))
+}
+
+func TestClient_UploadArtifactDirectory(t *testing.T) {
+	store := &artifactStore{files: make(map[string]string)}
+	server := newArtifactServer(store)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "gocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b"), 0644)
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.UploadArtifact("foo", 1, "bar", 1, "baz", "results", dir, nil))
+	assert.Equal(t, store.files, map[string]string{"results/a.txt": "a", "results/sub/b.txt": "b"})
+
+	assert.Equal(t, string(store.checksums), "results/a.txt="+md5Hex("a")+"\nresults/sub/b.txt="+md5Hex("b")+"\n")
+}
+
+func TestClient_AppendToArtifact(t *testing.T) {
+	store := &artifactStore{files: map[string]string{"logs/run.log": "first\n"}}
+	server := newArtifactServer(store)
+	defer server.Close()
+
+	var sent, total int64
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.AppendToArtifact("foo", 1, "bar", 1, "baz", "logs/run.log", strings.NewReader("second\n"), func(s, t int64) {
+		sent, total = s, t
+	}))
+	assert.Equal(t, store.files["logs/run.log"], "first\nsecond\n")
+	assert.Equal(t, sent, int64(7))
+	assert.Equal(t, total, int64(7))
+}
+
+func TestChecksums(t *testing.T) {
+	checksums := map[string]string{"a b/c=d.txt": md5Hex("x"), "plain.txt": md5Hex("y")}
+	data := formatChecksums(checksums)
+	assert.Equal(t, string(data), "a\\ b/c\\=d.txt="+md5Hex("x")+"\nplain.txt="+md5Hex("y")+"\n")
+}
@@ -0,0 +1,109 @@
+package gocd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to every request sent by a Client.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// WithAuthenticator replaces the basic auth credentials passed to New.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(p *Client) {
+		p.auth = auth
+	}
+}
+
+type BasicAuth struct {
+	Login    string
+	Password string
+}
+
+func (p BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(p.Login, p.Password)
+	return nil
+}
+
+// BearerToken authenticates with a GoCD personal access token.
+type BearerToken struct {
+	Token string
+}
+
+func (p BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.Token))
+	return nil
+}
+
+// Anonymous sends requests without credentials.
+type Anonymous struct{}
+
+func (p Anonymous) Authenticate(req *http.Request) error {
+	return nil
+}
+
+// EnvToken reads a bearer token from the environment variable Name on every
+// request, so a rotated token is picked up without recreating the Client.
+type EnvToken struct {
+	Name string
+}
+
+func (p EnvToken) Authenticate(req *http.Request) error {
+	token := strings.TrimSpace(os.Getenv(p.Name))
+	if token == "" {
+		return fmt.Errorf("Env %s is empty", p.Name)
+	}
+	return BearerToken{Token: token}.Authenticate(req)
+}
+
+// FileToken reads a bearer token from a file and reloads it whenever the
+// file modification time changes. It is safe for concurrent use.
+type FileToken struct {
+	path    string
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+}
+
+func NewFileToken(path string) *FileToken {
+	return &FileToken{path: path}
+}
+
+func (p *FileToken) Authenticate(req *http.Request) error {
+	token, err := p.load()
+	if err != nil {
+		return err
+	}
+	return BearerToken{Token: token}.Authenticate(req)
+}
+
+func (p *FileToken) load() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return "", err
+	}
+	if p.token != "" && info.ModTime().Equal(p.modTime) {
+		return p.token, nil
+	}
+
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("Token file %s is empty", p.path)
+	}
+	p.token, p.modTime = token, info.ModTime()
+	return p.token, nil
+}
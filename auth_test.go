@@ -0,0 +1,82 @@
+package gocd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthServer(headers *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*headers = append(*headers, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestClient_BasicAuth(t *testing.T) {
+	headers := make([]string, 0)
+	server := newAuthServer(&headers)
+	defer server.Close()
+
+	assert.NoError(t, New(server.URL, "admin", "secret", WithServerVersion("17.3.0")).UnpausePipeline("pipeline"))
+	assert.NoError(t, New(server.URL, "", "", WithServerVersion("17.3.0")).UnpausePipeline("pipeline"))
+	assert.Equal(t, headers, []string{"Basic YWRtaW46c2VjcmV0", ""})
+}
+
+func TestClient_BearerToken(t *testing.T) {
+	headers := make([]string, 0)
+	server := newAuthServer(&headers)
+	defer server.Close()
+
+	client := New(server.URL, "admin", "secret", WithAuthenticator(BearerToken{Token: "abc"}), WithServerVersion("17.3.0"))
+	assert.NoError(t, client.UnpausePipeline("pipeline"))
+	assert.Equal(t, headers, []string{"Bearer abc"})
+}
+
+func TestClient_EnvToken(t *testing.T) {
+	headers := make([]string, 0)
+	server := newAuthServer(&headers)
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithAuthenticator(EnvToken{Name: "GOCD_TEST_TOKEN"}), WithServerVersion("17.3.0"))
+	os.Unsetenv("GOCD_TEST_TOKEN")
+	assert.Error(t, client.UnpausePipeline("pipeline"))
+
+	os.Setenv("GOCD_TEST_TOKEN", "first")
+	defer os.Unsetenv("GOCD_TEST_TOKEN")
+	assert.NoError(t, client.UnpausePipeline("pipeline"))
+	os.Setenv("GOCD_TEST_TOKEN", "second")
+	assert.NoError(t, client.UnpausePipeline("pipeline"))
+	assert.Equal(t, headers, []string{"Bearer first", "Bearer second"})
+}
+
+func TestClient_FileToken(t *testing.T) {
+	headers := make([]string, 0)
+	server := newAuthServer(&headers)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "gocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+
+	client := New(server.URL, "", "", WithAuthenticator(NewFileToken(path)), WithServerVersion("17.3.0"))
+	assert.Error(t, client.UnpausePipeline("pipeline"))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("first\n"), 0600))
+	assert.NoError(t, client.UnpausePipeline("pipeline"))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("second\n"), 0600))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	assert.NoError(t, client.UnpausePipeline("pipeline"))
+	assert.Equal(t, headers, []string{"Bearer first", "Bearer second"})
+}
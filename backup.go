@@ -0,0 +1,35 @@
+package gocd
+
+import (
+	"time"
+)
+
+const (
+	BackupNotStarted = "NOT_STARTED"
+	BackupInProgress = "IN_PROGRESS"
+	BackupCompleted  = "COMPLETED"
+	BackupError      = "ERROR"
+)
+
+// DefaultBackupPollInterval is used by WaitForBackup when no interval is given.
+const DefaultBackupPollInterval = 5 * time.Second
+
+// Backup is a server backup. Servers from 19.3.0 back up asynchronously: ID
+// identifies the backup and Status tells whether it has finished.
+type Backup struct {
+	ID             string    `json:"-"`
+	Time           time.Time `json:"time"`
+	Path           string    `json:"path"`
+	User           User      `json:"user"`
+	Status         string    `json:"status"`
+	Message        string    `json:"message"`
+	ProgressStatus string    `json:"progress_status"`
+}
+
+func NewBackup() *Backup {
+	return &Backup{User: *NewUser()}
+}
+
+func (p *Backup) Done() bool {
+	return p.Status == BackupCompleted || p.Status == BackupError
+}
@@ -0,0 +1,220 @@
+package gocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupportedByServer is returned, wrapped, when the GoCD server is too
+// old or too new for an API the client knows how to speak.
+var ErrUnsupportedByServer = errors.New("Operation is not supported by the server")
+
+const (
+	apiAgents         = "agents"
+	apiUsers          = "users"
+	apiEnvironments   = "environments"
+	apiPipelineConfig = "pipeline config"
+	apiBackups        = "backups"
+	apiBackupStatus   = "backup status"
+	apiPipelineOps    = "pipeline operations"
+	apiDashboard      = "dashboard"
+	apiTemplates      = "templates"
+	apiGroups         = "pipeline groups"
+)
+
+// mediaType is one version of an endpoint: available from server version
+// Since up to, but not including, Until. An empty Accept is the unversioned
+// API older servers speak.
+type mediaType struct {
+	Accept string
+	Since  string
+	Until  string
+}
+
+// capabilities lists, oldest first, the media types the client understands
+// for every versioned endpoint.
+var capabilities = map[string][]mediaType{
+	apiAgents:         {{Accept: "application/vnd.go.cd.v2+json", Since: "16.1.0"}},
+	apiUsers:          {{Accept: "application/vnd.go.cd.v1+json", Since: "15.2.0"}},
+	apiEnvironments:   {{Accept: "application/vnd.go.cd.v1+json", Since: "16.7.0"}},
+	apiPipelineConfig: {{Accept: "application/vnd.go.cd.v2+json", Since: "16.6.0"}},
+	apiBackups: {
+		{Accept: "application/vnd.go.cd.v1+json", Since: "15.2.0", Until: "19.3.0"},
+		{Accept: "application/vnd.go.cd.v2+json", Since: "19.3.0"}},
+	apiBackupStatus: {{Accept: "application/vnd.go.cd.v2+json", Since: "19.3.0"}},
+	apiPipelineOps: {
+		{Accept: "", Until: "18.2.0"},
+		{Accept: "application/vnd.go.cd.v1+json", Since: "18.2.0"}},
+	apiDashboard: {{Accept: "application/vnd.go.cd.v1+json", Since: "16.1.0"}},
+	apiTemplates: {{Accept: "application/vnd.go.cd.v1+json", Since: "16.10.0"}},
+	apiGroups:    {{Accept: "application/vnd.go.cd.v1+json", Since: "18.6.0"}},
+}
+
+// WithServerVersion skips discovery and negotiates media types for the given
+// GoCD release, e.g. "17.3.0".
+func WithServerVersion(version string) Option {
+	return func(p *Client) {
+		p.server.version, p.server.known = version, true
+	}
+}
+
+// legacyServerVersion stands for servers without a version endpoint, which
+// predate 16.6.0 and so every versioned endpoint introduced since.
+const legacyServerVersion = "0"
+
+// versionRetryInterval is how long a failed version discovery is remembered
+// before the server is asked again.
+var versionRetryInterval = time.Minute
+
+type serverInfo struct {
+	mu          sync.Mutex
+	version     string
+	known       bool
+	retryAt     time.Time
+	discovering chan struct{}
+}
+
+// serverVersion asks the server for its version once. An empty result means
+// the version could not be determined; the server is not asked again for
+// versionRetryInterval. Concurrent callers wait for a single discovery, each
+// bounded by its own context.
+func (p *Client) serverVersion(ctx context.Context) (string, error) {
+	for {
+		p.server.mu.Lock()
+		if p.server.known {
+			version := p.server.version
+			p.server.mu.Unlock()
+			return version, nil
+		}
+		if time.Now().Before(p.server.retryAt) {
+			p.server.mu.Unlock()
+			return "", nil
+		}
+		if wait := p.server.discovering; wait != nil {
+			p.server.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		p.server.discovering = done
+		p.server.mu.Unlock()
+
+		version, known, err := p.discoverVersion(ctx)
+
+		p.server.mu.Lock()
+		switch true {
+		case known:
+			p.server.version, p.server.known = version, true
+		case err == nil:
+			p.server.retryAt = time.Now().Add(versionRetryInterval)
+		}
+		p.server.discovering = nil
+		close(done)
+		p.server.mu.Unlock()
+		return version, err
+	}
+}
+
+func (p *Client) discoverVersion(ctx context.Context) (string, bool, error) {
+	version, err := p.VersionContext(ctx)
+	switch true {
+	case err != nil && ctx.Err() != nil:
+		return "", false, ctx.Err()
+	case err != nil && IsNotFound(err):
+		// servers before 16.6.0 have no version endpoint, but neither has
+		// a misrouting proxy: only take a server that answers an older API
+		// for a legacy GoCD
+		if _, err := p.GetGroupsContext(ctx); err == nil {
+			return legacyServerVersion, true, nil
+		}
+		return "", false, ctx.Err()
+	case err != nil:
+		return "", false, nil
+	}
+	if _, ok := parseVersion(version.Version); !ok {
+		return "", true, nil
+	}
+	return version.Version, true, nil
+}
+
+// accept returns the newest media type of api supported by the server. When
+// the server version is unknown the newest media type is used.
+func (p *Client) accept(ctx context.Context, api string) (string, error) {
+	types := capabilities[api]
+	version, err := p.serverVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := parseVersion(version); !ok {
+		return types[len(types)-1].Accept, nil
+	}
+
+	for i := len(types) - 1; i >= 0; i-- {
+		if compareVersions(version, types[i].Since) >= 0 &&
+			(types[i].Until == "" || compareVersions(version, types[i].Until) < 0) {
+			return types[i].Accept, nil
+		}
+	}
+	if compareVersions(version, types[0].Since) < 0 {
+		server := version
+		if version == legacyServerVersion {
+			server = "older than 16.6.0"
+		}
+		return "", fmt.Errorf("%w: %s API requires GoCD %s, server is %s", ErrUnsupportedByServer, api, types[0].Since, server)
+	}
+	return "", fmt.Errorf("%w: %s API is not available in GoCD %s", ErrUnsupportedByServer, api, version)
+}
+
+// withAccept adds accept to headers unless the API is unversioned.
+func withAccept(headers map[string]string, accept string) map[string]string {
+	if accept != "" {
+		headers["Accept"] = accept
+	}
+	return headers
+}
+
+func parseVersion(version string) ([]int, bool) {
+	if version == "" {
+		return nil, false
+	}
+	parts := strings.Split(strings.SplitN(version, " ", 2)[0], ".")
+	result := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		result = append(result, n)
+	}
+	return result, true
+}
+
+func compareVersions(a, b string) int {
+	va, _ := parseVersion(a)
+	vb, _ := parseVersion(b)
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
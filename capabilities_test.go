@@ -0,0 +1,176 @@
+package gocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newVersionServer(version string, versions *int, accepts *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/go/api/version" {
+			*versions++
+			fmt.Fprintf(w, `{"version":"%s","build_number":"3637"}`, version)
+			return
+		}
+		*accepts = append(*accepts, r.Header.Get("Accept"))
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestClient_NegotiateMediaType(t *testing.T) {
+	versions, accepts := 0, make([]string, 0)
+	server := newVersionServer("16.7.0", &versions, &accepts)
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.DeleteAgent("uuid"))
+	assert.NoError(t, client.DeleteEnvironment("env"))
+	assert.Equal(t, versions, 1)
+	assert.Equal(t, accepts, []string{"application/vnd.go.cd.v2+json", "application/vnd.go.cd.v1+json"})
+}
+
+func TestClient_UnsupportedByServer(t *testing.T) {
+	versions, accepts := 0, make([]string, 0)
+	server := newVersionServer("16.6.0", &versions, &accepts)
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	err := client.DeleteEnvironment("env")
+	assert.True(t, errors.Is(err, ErrUnsupportedByServer))
+	assert.Equal(t, len(accepts), 0)
+}
+
+func TestClient_NegotiateUntil(t *testing.T) {
+	capabilities["test"] = []mediaType{
+		{Accept: "application/vnd.go.cd.v1+json", Since: "16.1.0", Until: "17.1.0"},
+		{Accept: "application/vnd.go.cd.v2+json", Since: "16.10.0", Until: "18.1.0"}}
+	defer delete(capabilities, "test")
+
+	for version, accept := range map[string]string{
+		"16.9.0":  "application/vnd.go.cd.v1+json",
+		"16.10.0": "application/vnd.go.cd.v2+json",
+		"17.12.0": "application/vnd.go.cd.v2+json",
+		"":        "application/vnd.go.cd.v2+json"} {
+		result, err := New("", "", "", WithServerVersion(version)).accept(context.Background(), "test")
+		assert.NoError(t, err)
+		assert.Equal(t, result, accept, version)
+	}
+	for _, version := range []string{"15.2.0", "18.1.0"} {
+		_, err := New("", "", "", WithServerVersion(version)).accept(context.Background(), "test")
+		assert.True(t, errors.Is(err, ErrUnsupportedByServer), version)
+	}
+}
+
+func TestClient_LegacyServer(t *testing.T) {
+	versions, accepts, bodies := 0, make([]string, 0), make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go/api/version":
+			versions++
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/go/api/config/pipeline_groups":
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		accepts = append(accepts, r.Header.Get("Accept"))
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.PausePipeline("pipeline", "deploy freeze"))
+	_, err := client.GetPipelineGroup("first")
+	assert.True(t, errors.Is(err, ErrUnsupportedByServer))
+	assert.True(t, strings.Contains(err.Error(), "older than 16.6.0"), err.Error())
+
+	assert.Equal(t, versions, 1)
+	assert.Equal(t, accepts, []string{""})
+	assert.Equal(t, bodies, []string{"pauseCause=deploy+freeze"})
+}
+
+func TestClient_DiscoveryFailure(t *testing.T) {
+	versions, accepts := 0, make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go/api/version":
+			versions++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		accepts = append(accepts, r.Header.Get("Accept"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.DeleteAgent("uuid"))
+	assert.NoError(t, client.DeleteAgent("uuid"))
+	assert.Equal(t, versions, 1)
+
+	client.server.retryAt = time.Now()
+	assert.NoError(t, client.DeleteAgent("uuid"))
+	assert.Equal(t, versions, 2)
+	assert.Equal(t, accepts, []string{"application/vnd.go.cd.v2+json",
+		"application/vnd.go.cd.v2+json", "application/vnd.go.cd.v2+json"})
+}
+
+func TestClient_MisroutedVersion(t *testing.T) {
+	versions, accepts := 0, make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go/api/version":
+			versions++
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/go/api/config/pipeline_groups":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		accepts = append(accepts, r.Header.Get("Accept"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	assert.NoError(t, client.DeleteEnvironment("env"))
+	assert.Equal(t, versions, 1)
+	assert.Equal(t, accepts, []string{"application/vnd.go.cd.v1+json"})
+}
+
+func TestClient_DiscoveryHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/go/api/version" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := New(server.URL, "", "")
+	go client.DeleteAgent("uuid")
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := client.DeleteAgentContext(ctx, "uuid")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < time.Second, fmt.Sprint(time.Since(start)))
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, compareVersions("16.10.0", "16.9.1"), 1)
+	assert.Equal(t, compareVersions("16.7", "16.7.0"), 0)
+	assert.Equal(t, compareVersions("16.7.0 (3637-8a6d6b1e4f)", "17.1.0"), -1)
+}
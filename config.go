@@ -0,0 +1,210 @@
+package gocd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// ConfigModification is one change to the server configuration, newest
+// first in the revision history. Time is in milliseconds since the epoch.
+type ConfigModification struct {
+	MD5           string `json:"md5"`
+	Username      string `json:"username"`
+	GoVersion     string `json:"goVersion"`
+	Time          int    `json:"time"`
+	SchemaVersion int    `json:"schemaVersion"`
+	CommitSHA     string `json:"commitSHA"`
+}
+
+// configGroup is a <pipelines> element of cruise-config.xml, located by byte
+// offsets so that edits leave the rest of the file untouched.
+type configGroup struct {
+	name string
+	// start and end delimit the start tag; close is the offset of the end
+	// tag, or -1 when the element is self-closing
+	start, end, close int
+	// pipelines maps every pipeline of the group to the span of its element
+	pipelines map[string][2]int
+}
+
+func parseConfigGroups(config []byte) ([]*configGroup, error) {
+	groups := make([]*configGroup, 0)
+	decoder := xml.NewDecoder(bytes.NewReader(config))
+	var group *configGroup
+	depth, pipeline, pipelineStart := 0, "", 0
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return groups, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch true {
+			case depth == 2 && t.Name.Local == "pipelines":
+				group = &configGroup{name: xmlAttr(t, "group"), start: offset,
+					end: int(decoder.InputOffset()), pipelines: make(map[string][2]int)}
+				groups = append(groups, group)
+			case depth == 3 && group != nil && t.Name.Local == "pipeline":
+				pipeline, pipelineStart = xmlAttr(t, "name"), offset
+			}
+		case xml.EndElement:
+			switch true {
+			case depth == 2 && group != nil:
+				// a self-closing element ends without consuming any input
+				group.close = offset
+				if offset == int(decoder.InputOffset()) {
+					group.close = -1
+				}
+				group = nil
+			case depth == 3 && group != nil && pipeline != "":
+				group.pipelines[pipeline] = [2]int{pipelineStart, int(decoder.InputOffset())}
+				pipeline = ""
+			}
+			depth--
+		}
+	}
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func findConfigGroup(groups []*configGroup, name string) *configGroup {
+	for _, group := range groups {
+		if group.name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// lineStart moves offset back over the indentation in front of it.
+func lineStart(data []byte, offset int) int {
+	i := offset
+	for i > 0 && (data[i-1] == ' ' || data[i-1] == '\t') {
+		i--
+	}
+	if i == 0 || data[i-1] == '\n' {
+		return i
+	}
+	return offset
+}
+
+// lineEnd moves offset forward past the end of its line when nothing but
+// whitespace follows it.
+func lineEnd(data []byte, offset int) int {
+	i := offset
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r') {
+		i++
+	}
+	if i < len(data) && data[i] == '\n' {
+		return i + 1
+	}
+	return offset
+}
+
+// moveConfigPipeline moves the <pipeline> element of pipeline into group.
+func moveConfigPipeline(config []byte, pipeline, group string) ([]byte, error) {
+	groups, err := parseConfigGroups(config)
+	if err != nil {
+		return nil, err
+	}
+	var from *configGroup
+	for _, g := range groups {
+		if _, ok := g.pipelines[pipeline]; ok {
+			from = g
+		}
+	}
+	to := findConfigGroup(groups, group)
+	switch true {
+	case from == nil:
+		return nil, fmt.Errorf("%s not found", pipeline)
+	case to == nil:
+		return nil, fmt.Errorf("Pipeline group %s not found", group)
+	case from == to:
+		return config, nil
+	}
+
+	span := from.pipelines[pipeline]
+	cut := [2]int{lineStart(config, span[0]), lineEnd(config, span[1])}
+	element := config[cut[0]:cut[1]]
+
+	var insert [2]int
+	var text []byte
+	if to.close >= 0 {
+		insert = [2]int{lineStart(config, to.close), lineStart(config, to.close)}
+		text = element
+	} else {
+		// expand <pipelines group="..." /> to hold the pipeline
+		insert = [2]int{to.start, to.end}
+		tag := bytes.TrimRight(config[to.start:to.end-2], " \t\r\n")
+		text = append(append([]byte{}, tag...), '>')
+		if bytes.HasSuffix(element, []byte("\n")) {
+			text = append(text, '\n')
+		}
+		text = append(text, element...)
+		text = append(text, config[lineStart(config, to.start):to.start]...)
+		text = append(text, "</pipelines>"...)
+	}
+
+	result := make([]byte, 0, len(config)+len(text))
+	if insert[0] < cut[0] {
+		result = append(result, config[:insert[0]]...)
+		result = append(result, text...)
+		result = append(result, config[insert[1]:cut[0]]...)
+		result = append(result, config[cut[1]:]...)
+	} else {
+		result = append(result, config[:cut[0]]...)
+		result = append(result, config[cut[1]:insert[0]]...)
+		result = append(result, text...)
+		result = append(result, config[insert[1]:]...)
+	}
+	return result, nil
+}
+
+var groupAttr = regexp.MustCompile(`(\sgroup\s*=\s*)("[^"]*"|'[^']*')`)
+
+// renameConfigGroup changes the name of the <pipelines> element of name.
+func renameConfigGroup(config []byte, name, newName string) ([]byte, error) {
+	groups, err := parseConfigGroups(config)
+	if err != nil {
+		return nil, err
+	}
+	group := findConfigGroup(groups, name)
+	switch true {
+	case group == nil:
+		return nil, fmt.Errorf("Pipeline group %s not found", name)
+	case name == newName:
+		return config, nil
+	case findConfigGroup(groups, newName) != nil:
+		return nil, fmt.Errorf("Pipeline group %s already exists", newName)
+	}
+
+	value := bytes.Buffer{}
+	value.WriteByte('"')
+	if err := xml.EscapeText(&value, []byte(newName)); err != nil {
+		return nil, err
+	}
+	value.WriteByte('"')
+	tag := groupAttr.ReplaceAllFunc(config[group.start:group.end], func(attr []byte) []byte {
+		prefix := groupAttr.FindSubmatch(attr)[1]
+		return append(append([]byte{}, prefix...), value.Bytes()...)
+	})
+
+	result := append([]byte{}, config[:group.start]...)
+	result = append(result, tag...)
+	return append(result, config[group.end:]...), nil
+}
@@ -0,0 +1,97 @@
+package gocd
+
+import (
+	"encoding/json"
+)
+
+// Dashboard is the view of the GoCD home page: every pipeline group with the
+// latest instances of its pipelines.
+type Dashboard struct {
+	Groups []*DashboardGroup
+}
+
+type DashboardGroup struct {
+	Group     Group
+	Pipelines []*DashboardPipeline
+}
+
+type DashboardPipeline struct {
+	Name      string              `json:"name"`
+	Label     string              `json:"label"`
+	Locked    bool                `json:"locked"`
+	PauseInfo PipelinePauseInfo   `json:"pause_info"`
+	Instances []*PipelineInstance `json:"-"`
+}
+
+type PipelinePauseInfo struct {
+	Paused      bool   `json:"paused"`
+	PausedBy    string `json:"paused_by"`
+	PauseReason string `json:"pause_reason"`
+}
+
+func NewDashboard() *Dashboard {
+	return &Dashboard{Groups: make([]*DashboardGroup, 0)}
+}
+
+// UnmarshalJSON flattens the HAL _embedded documents of the dashboard API.
+func (p *Dashboard) UnmarshalJSON(data []byte) error {
+	type instance struct {
+		PipelineInstance
+		Embedded struct {
+			Stages []Stage `json:"stages"`
+		} `json:"_embedded"`
+	}
+	type pipeline struct {
+		DashboardPipeline
+		Embedded struct {
+			Instances []instance `json:"instances"`
+		} `json:"_embedded"`
+	}
+	dashboard := struct {
+		Embedded struct {
+			Groups []struct {
+				Name     string `json:"name"`
+				Embedded struct {
+					Pipelines []pipeline `json:"pipelines"`
+				} `json:"_embedded"`
+			} `json:"pipeline_groups"`
+		} `json:"_embedded"`
+	}{}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		return err
+	}
+
+	p.Groups = make([]*DashboardGroup, 0, len(dashboard.Embedded.Groups))
+	for _, g := range dashboard.Embedded.Groups {
+		group := &DashboardGroup{Group: Group{Name: g.Name}, Pipelines: make([]*DashboardPipeline, 0)}
+		for _, pp := range g.Embedded.Pipelines {
+			result := pp.DashboardPipeline
+			result.Instances = make([]*PipelineInstance, 0, len(pp.Embedded.Instances))
+			for _, i := range pp.Embedded.Instances {
+				inst := i.PipelineInstance
+				inst.Name = pp.Name
+				inst.Stages = i.Embedded.Stages
+				result.Instances = append(result.Instances, &inst)
+			}
+			group.Group.Pipelines = append(group.Group.Pipelines, struct {
+				Name string `json:"name"`
+			}{pp.Name})
+			group.Pipelines = append(group.Pipelines, &result)
+		}
+		p.Groups = append(p.Groups, group)
+	}
+	return nil
+}
+
+// Pipeline returns the named pipeline, or nil when it is not on the
+// dashboard.
+func (p *Dashboard) Pipeline(name string) *DashboardPipeline {
+	for _, group := range p.Groups {
+		for _, pipeline := range group.Pipelines {
+			if pipeline.Name == name {
+				return pipeline
+			}
+		}
+	}
+	return nil
+}
@@ -33,6 +33,7 @@ type EnvironmentVariable struct {
 }
 
 type Environment struct {
+	Etag                 string                   `json:"-"`
 	Links                Links                    `json:"_links"`
 	Name                 string                   `json:"name"`
 	Agents               []ShortAgent             `json:"agents"`
@@ -125,7 +126,8 @@ func (p *Environment) DeleteEnvironmentVariables(name string) error {
 }
 
 type Environments struct {
-	Links   Links `json:"_links"`
+	Etag    string `json:"-"`
+	Links   Links  `json:"_links"`
 	Embeded struct {
 		Environments []Environment `json:"environments"`
 	} `json:"_embedded"`
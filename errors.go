@@ -0,0 +1,102 @@
+package gocd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned for every response with an unexpected status code.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Method     string
+	URL        string
+	Body       []byte
+	// Message and Errors are parsed from the GoCD JSON error body. Errors holds
+	// the per-field validation messages reported by the admin endpoints.
+	Message string
+	Errors  map[string][]string
+	Data    json.RawMessage
+}
+
+func (p *APIError) Error() string {
+	message := p.Message
+	if message == "" {
+		message = strings.TrimSpace(string(p.Body))
+	}
+	if message == "" {
+		return fmt.Sprintf("Operation error: %s %s: %s", p.Method, p.URL, p.Status)
+	}
+	return fmt.Sprintf("Operation error: %s %s: %s (%s)", p.Method, p.URL, p.Status, message)
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	err := &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	if resp.Request != nil {
+		err.Method = resp.Request.Method
+		err.URL = resp.Request.URL.String()
+	}
+
+	data := struct {
+		Message string              `json:"message"`
+		Errors  map[string][]string `json:"errors"`
+		Data    json.RawMessage     `json:"data"`
+	}{}
+	if json.Unmarshal(body, &data) != nil {
+		return err
+	}
+	err.Message, err.Errors, err.Data = data.Message, data.Errors, data.Data
+
+	nested := struct {
+		Errors map[string][]string `json:"errors"`
+	}{}
+	if len(data.Data) > 0 && json.Unmarshal(data.Data, &nested) == nil {
+		for field, messages := range nested.Errors {
+			if err.Errors == nil {
+				err.Errors = make(map[string][]string)
+			}
+			err.Errors[field] = append(err.Errors[field], messages...)
+		}
+	}
+	return err
+}
+
+func hasStatus(err error, codes ...int) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports both 409 Conflict and 412 Precondition Failed, which
+// GoCD returns when the If-Match ETag is stale.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict, http.StatusPreconditionFailed)
+}
+
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// IsValidation reports a 422 Unprocessable Entity; inspect APIError.Errors
+// for the offending fields.
+func IsValidation(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity)
+}
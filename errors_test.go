@@ -0,0 +1,60 @@
+package gocd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_APIErrorNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"Either the resource you requested was not found, or you are not authorized to perform this action."}`)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("16.7.0"))
+	_, err := client.GetPipelineConfig("missing")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, apiErr.StatusCode, http.StatusNotFound)
+	assert.Equal(t, apiErr.Method, "GET")
+	assert.Equal(t, apiErr.URL, server.URL+"/go/api/admin/pipelines/missing")
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsConflict(err))
+}
+
+func TestClient_APIErrorValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprint(w, `{"message":"Validations failed for environment 'my env'.",
+			"data":{"name":"my env","errors":{"name":["Invalid environment name 'my env'."]}}}`)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	err := client.NewEnvironment(NewEnvironment())
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.True(t, IsValidation(err))
+	assert.Equal(t, apiErr.Message, "Validations failed for environment 'my env'.")
+	assert.Equal(t, apiErr.Errors, map[string][]string{"name": {"Invalid environment name 'my env'."}})
+}
+
+func TestAPIError_Status(t *testing.T) {
+	assert.True(t, IsConflict(&APIError{StatusCode: http.StatusPreconditionFailed}))
+	assert.True(t, IsConflict(fmt.Errorf("wrapped: %w", &APIError{StatusCode: http.StatusConflict})))
+	assert.True(t, IsUnauthorized(&APIError{StatusCode: http.StatusUnauthorized}))
+	assert.True(t, IsForbidden(&APIError{StatusCode: http.StatusForbidden}))
+	assert.False(t, IsNotFound(errors.New("not found")))
+	assert.Equal(t, (&APIError{Method: "GET", URL: "http://gocd/go/api", Status: "500 Internal Server Error", Body: []byte("boom")}).Error(),
+		"Operation error: GET http://gocd/go/api: 500 Internal Server Error (boom)")
+}
@@ -0,0 +1,92 @@
+package gocd
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+const (
+	etagPipeline    = "pipeline"
+	etagEnvironment = "environment"
+	etagTemplate    = "template"
+	etagGroup       = "group"
+)
+
+// ErrNoEtag is returned, wrapped, when an object is written without the ETag
+// it was fetched with, which would blindly overwrite concurrent changes.
+var ErrNoEtag = errors.New("No ETag to write the resource with")
+
+// DefaultUpdateAttempts is how many times the Update helpers refetch and
+// reapply their mutation when the server reports a stale ETag.
+const DefaultUpdateAttempts = 5
+
+// WithUpdateAttempts overrides DefaultUpdateAttempts.
+func WithUpdateAttempts(attempts int) Option {
+	return func(p *Client) {
+		p.updates = attempts
+	}
+}
+
+// etagStore remembers the last ETag seen for every resource, keyed by the
+// resource kind and name, so writes to one resource never send the ETag of
+// another. It is safe for concurrent use.
+type etagStore struct {
+	mu   sync.RWMutex
+	tags map[string]string
+}
+
+func (p *etagStore) key(kind, name string) string {
+	return kind + "/" + name
+}
+
+func (p *etagStore) get(kind, name string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.tags[p.key(kind, name)]
+}
+
+func (p *etagStore) set(kind, name, tag string) {
+	if tag == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tags == nil {
+		p.tags = make(map[string]string)
+	}
+	p.tags[p.key(kind, name)] = tag
+}
+
+func (p *etagStore) delete(kind, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.tags, p.key(kind, name))
+}
+
+// update stores the ETag of resp, if any, and returns it.
+func (p *etagStore) update(kind, name string, resp *http.Response) string {
+	tag := resp.Header.Get("Etag")
+	p.set(kind, name, tag)
+	return tag
+}
+
+// ifMatch picks the ETag the caller fetched the object with, falling back to
+// the last one the client has seen for that resource.
+func (p *etagStore) ifMatch(kind, name, tag string) string {
+	if tag != "" {
+		return tag
+	}
+	return p.get(kind, name)
+}
+
+// retryConflict runs a read-modify-write cycle until it succeeds, fails with
+// an error other than a conflict, or runs out of attempts.
+func (p *Client) retryConflict(cycle func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := cycle()
+		if err == nil || !IsConflict(err) || attempt >= p.updates {
+			return err
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package gocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newEtagServer(t *testing.T) *httptest.Server {
+	data, err := ioutil.ReadFile(createPath("get_pipeline_config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	versions := make(map[string]int)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		etag := fmt.Sprintf(`"%s-%d"`, name, versions[name])
+		switch r.Method {
+		case "GET":
+			w.Header().Set("Etag", etag)
+			w.Write(data)
+		case "PUT":
+			if r.Header.Get("If-Match") != etag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			versions[name]++
+			w.Header().Set("Etag", fmt.Sprintf(`"%s-%d"`, name, versions[name]))
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+}
+
+func TestClient_EtagPerResource(t *testing.T) {
+	server := newEtagServer(t)
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	first, err := client.GetPipelineConfig("first")
+	assert.NoError(t, err)
+	assert.Equal(t, first.Etag, `"first-0"`)
+	second, err := client.GetPipelineConfig("second")
+	assert.NoError(t, err)
+
+	first.Name, second.Name = "first", "second"
+	assert.NoError(t, client.SetPipelineConfig(first))
+	assert.Equal(t, first.Etag, `"first-1"`)
+	assert.NoError(t, client.SetPipelineConfigRaw("second", []byte(`{}`)))
+	assert.True(t, IsConflict(client.SetPipelineConfig(second)))
+}
+
+func TestClient_EtagConcurrent(t *testing.T) {
+	server := newEtagServer(t)
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			_, err := client.GetPipelineConfig(name)
+			if err == nil {
+				err = client.SetPipelineConfigRaw(name, []byte(`{}`))
+			}
+			errs <- err
+		}(fmt.Sprintf("pipeline%d", i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestClient_UpdatePipelineConfig(t *testing.T) {
+	server := newEtagServer(t)
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	calls := 0
+	pipeline, err := client.UpdatePipelineConfig("my_pipeline", func(pipeline *PipelineConfig) error {
+		calls++
+		if calls == 1 {
+			// another writer gets in between our read and write
+			if err := client.SetPipelineConfigRaw("my_pipeline", []byte(`{}`)); err != nil {
+				return err
+			}
+		}
+		pipeline.LabelTemplate = "${COUNT}-updated"
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, calls, 2)
+	assert.Equal(t, pipeline.LabelTemplate, "${COUNT}-updated")
+	assert.Equal(t, pipeline.Etag, `"my_pipeline-2"`)
+}
+
+func TestClient_UpdatePipelineConfigAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			data, _ := ioutil.ReadFile(createPath("get_pipeline_config"))
+			w.Write(data)
+			return
+		}
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithUpdateAttempts(3))
+	calls := 0
+	_, err := client.UpdatePipelineConfig("my_pipeline", func(pipeline *PipelineConfig) error {
+		calls++
+		return nil
+	})
+	assert.True(t, IsConflict(err))
+	assert.Equal(t, calls, 3)
+}
+
+func TestClient_UpdateAgent(t *testing.T) {
+	patches := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			data, _ := ioutil.ReadFile(createPath("get_agent"))
+			w.Write(data)
+		case "PATCH":
+			body, _ := ioutil.ReadAll(r.Body)
+			patches = append(patches, string(body))
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	agent, err := client.UpdateAgent("adb9540a-b954-4571-9d9b-2f330739d4da", func(agent *Agent) error {
+		agent.Resources = append(agent.Resources, "docker")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, agent.Resources, []string{"java", "linux", "firefox", "docker"})
+	assert.Equal(t, patches, []string{`{"resources":["java","linux","firefox","docker"]}`})
+}
+
+func TestClient_UpdateTemplate(t *testing.T) {
+	data, err := ioutil.ReadFile(createPath("get_template"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, conflicts := 0, 1
+	var put []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Header().Set("Etag", fmt.Sprintf(`"%d"`, version))
+			w.Write(data)
+		case "PUT":
+			put, _ = ioutil.ReadAll(r.Body)
+			if conflicts > 0 {
+				conflicts--
+				version++
+			}
+			if r.Header.Get("If-Match") != fmt.Sprintf(`"%d"`, version) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			version++
+			w.Header().Set("Etag", fmt.Sprintf(`"%d"`, version))
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "")
+	attempts := 0
+	template, err := client.UpdateTemplate("build-and-test", func(p *TemplateConfig) error {
+		attempts++
+		p.Stages[0].Jobs[0].Timeout = 30
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, attempts, 2)
+	assert.Equal(t, template.Etag, `"2"`)
+
+	var expected, actual map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &expected))
+	assert.NoError(t, json.Unmarshal(put, &actual))
+	delete(expected, "_links")
+	expected["stages"].([]interface{})[0].(map[string]interface{})["jobs"].([]interface{})[0].(map[string]interface{})["timeout"] = float64(30)
+	assertContains(t, actual, expected, "template")
+}
+
+// assertContains checks that every field of expected, however deeply nested,
+// is in actual with the same value.
+func assertContains(t *testing.T, actual, expected interface{}, path string) {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !assert.True(t, ok, path) {
+			return
+		}
+		for key, value := range e {
+			assertContains(t, a[key], value, path+"."+key)
+		}
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !assert.True(t, ok, path) || !assert.Equal(t, len(a), len(e), path) {
+			return
+		}
+		for i := range e {
+			assertContains(t, a[i], e[i], fmt.Sprintf("%s[%d]", path, i))
+		}
+	default:
+		assert.Equal(t, actual, expected, path)
+	}
+}
+
+func TestClient_UpdatePipelineConfigKeepsUnknownFields(t *testing.T) {
+	data, err := ioutil.ReadFile(createPath("get_pipeline_config_full"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var put []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Header().Set("Etag", `"1"`)
+			w.Write(data)
+		case "PUT":
+			put, _ = ioutil.ReadAll(r.Body)
+			w.Header().Set("Etag", `"2"`)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithServerVersion("17.3.0"))
+	_, err = client.UpdatePipelineConfig("deploy", func(pipeline *PipelineConfig) error {
+		pipeline.Stages[0].Jobs[0].Timeout = 30
+		return nil
+	})
+	assert.NoError(t, err)
+
+	var expected, actual map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &expected))
+	assert.NoError(t, json.Unmarshal(put, &actual))
+	delete(expected, "_links")
+	expected["stages"].([]interface{})[0].(map[string]interface{})["jobs"].([]interface{})[0].(map[string]interface{})["timeout"] = float64(30)
+	assertContains(t, actual, expected, "pipeline")
+	assert.Equal(t, actual["_links"], nil)
+}
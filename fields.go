@@ -0,0 +1,67 @@
+package gocd
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// unknownFields holds the JSON fields of a config its struct does not model,
+// so that reading a config, changing it and writing it back keeps settings
+// this package knows nothing about.
+type unknownFields map[string]json.RawMessage
+
+// unmarshalKnown decodes data into v, a pointer to a struct without an
+// UnmarshalJSON method, and returns the fields v has no place for. HAL
+// _links are dropped as the server generates them.
+func unmarshalKnown(data []byte, v interface{}) (unknownFields, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	fields := make(unknownFields)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{"_links": true}
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+	for name := range fields {
+		if known[strings.ToLower(name)] {
+			delete(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// marshalKnown encodes v, a struct without a MarshalJSON method, along with
+// the extra fields it was decoded with.
+func marshalKnown(v interface{}, extra unknownFields) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
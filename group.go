@@ -19,3 +19,38 @@ func (p *Group) Exist(pipeline string) bool {
 	}
 	return false
 }
+
+// PipelineGroupConfig is the administrative view of a pipeline group.
+// Pipelines is read only; use MovePipelineToGroup to change it.
+type PipelineGroupConfig struct {
+	Etag          string             `json:"-"`
+	Name          string             `json:"name"`
+	Authorization GroupAuthorization `json:"authorization"`
+	Pipelines     []struct {
+		Name string `json:"name"`
+	} `json:"pipelines,omitempty"`
+}
+
+func NewPipelineGroupConfig(name string) *PipelineGroupConfig {
+	return &PipelineGroupConfig{Name: name, Authorization: NewGroupAuthorization()}
+}
+
+// GroupAuthorization lists who may view, operate and administer the
+// pipelines of a group. A group without any permissions is open to everyone.
+type GroupAuthorization struct {
+	View    GroupPermission `json:"view"`
+	Operate GroupPermission `json:"operate"`
+	Admins  GroupPermission `json:"admins"`
+}
+
+type GroupPermission struct {
+	Users []string `json:"users"`
+	Roles []string `json:"roles"`
+}
+
+func NewGroupAuthorization() GroupAuthorization {
+	return GroupAuthorization{
+		View:    GroupPermission{Users: make([]string, 0), Roles: make([]string, 0)},
+		Operate: GroupPermission{Users: make([]string, 0), Roles: make([]string, 0)},
+		Admins:  GroupPermission{Users: make([]string, 0), Roles: make([]string, 0)}}
+}
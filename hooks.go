@@ -0,0 +1,175 @@
+package gocd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+// Logger receives one line per request sent by the Client. *log.Logger
+// satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// RequestEvent describes a request about to be sent. Credentials in Header
+// and secrets in Body are masked.
+type RequestEvent struct {
+	Method  string
+	URL     string
+	Header  http.Header
+	Body    []byte
+	Attempt int
+}
+
+// ResponseEvent describes the outcome of a request. Body is only captured for
+// JSON responses; Err is set when no response was received.
+type ResponseEvent struct {
+	Request    *RequestEvent
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Latency    time.Duration
+	Err        error
+}
+
+// BeforeRequestHook is called before every attempt of a request. The context
+// it returns is used for the request, so tracers can attach a span to it.
+type BeforeRequestHook func(ctx context.Context, event *RequestEvent) context.Context
+
+// AfterResponseHook is called with the context returned by the
+// BeforeRequestHook once the attempt has completed.
+type AfterResponseHook func(ctx context.Context, event *ResponseEvent)
+
+func WithLogger(logger Logger) Option {
+	return func(p *Client) {
+		p.logger = logger
+	}
+}
+
+func WithBeforeRequest(hook BeforeRequestHook) Option {
+	return func(p *Client) {
+		p.beforeRequest = append(p.beforeRequest, hook)
+	}
+}
+
+func WithAfterResponse(hook AfterResponseHook) Option {
+	return func(p *Client) {
+		p.afterResponse = append(p.afterResponse, hook)
+	}
+}
+
+func (p *Client) hooked() bool {
+	return p.logger != nil || len(p.beforeRequest) > 0 || len(p.afterResponse) > 0
+}
+
+func (p *Client) runBeforeRequest(ctx context.Context, req *http.Request, body []byte, attempt int) (context.Context, *RequestEvent) {
+	event := &RequestEvent{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Header:  redactHeader(req.Header),
+		Body:    redactBody(req.Header.Get("Content-Type"), body),
+		Attempt: attempt}
+	for _, hook := range p.beforeRequest {
+		ctx = hook(ctx, event)
+	}
+	return ctx, event
+}
+
+func (p *Client) runAfterResponse(ctx context.Context, request *RequestEvent, resp *http.Response, err error, latency time.Duration) {
+	event := &ResponseEvent{Request: request, Latency: latency, Err: err}
+	if resp != nil {
+		event.StatusCode = resp.StatusCode
+		event.Header = redactHeader(resp.Header)
+		if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+			if body, err := ioutil.ReadAll(resp.Body); err == nil {
+				resp.Body.Close()
+				resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+				event.Body = redactBody(resp.Header.Get("Content-Type"), body)
+			}
+		}
+	}
+
+	if p.logger != nil {
+		if err != nil {
+			p.logger.Printf("gocd: %s %s: %v (%s)", request.Method, request.URL, err, latency)
+		} else {
+			p.logger.Printf("gocd: %s %s: %d (%s)", request.Method, request.URL, event.StatusCode, latency)
+		}
+	}
+	for _, hook := range p.afterResponse {
+		hook(ctx, event)
+	}
+}
+
+func redactHeader(header http.Header) http.Header {
+	result := header.Clone()
+	for _, name := range []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"} {
+		if _, ok := result[name]; ok {
+			result.Set(name, redacted)
+		}
+	}
+	return result
+}
+
+// redactBody masks passwords, tokens and secure values in JSON and form
+// encoded bodies.
+func redactBody(contentType string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	switch true {
+	case strings.Contains(contentType, "json"):
+		var data interface{}
+		if json.Unmarshal(body, &data) != nil {
+			return body
+		}
+		if result, err := json.Marshal(redactValue(data)); err == nil {
+			return result
+		}
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		for key := range values {
+			if secretKey(key) || strings.HasPrefix(key, "secure_variables") {
+				values[key] = []string{redacted}
+			}
+		}
+		return []byte(values.Encode())
+	}
+	return body
+}
+
+func redactValue(data interface{}) interface{} {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		secure, _ := value["secure"].(bool)
+		for k, v := range value {
+			if secretKey(k) || (secure && k == "value") {
+				value[k] = redacted
+			} else {
+				value[k] = redactValue(v)
+			}
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = redactValue(v)
+		}
+	}
+	return data
+}
+
+func secretKey(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "password") || strings.Contains(key, "token") ||
+		strings.Contains(key, "secret") || key == "encrypted_value"
+}
@@ -0,0 +1,79 @@
+package gocd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLogger []string
+
+func (p *testLogger) Printf(format string, v ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, v...))
+}
+
+type spanKey struct{}
+
+func TestClient_Hooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Etag", "123456789")
+		fmt.Fprint(w, `{"name":"TEST","environment_variables":[{"name":"PASS","secure":true,"encrypted_value":"xyz"}]}`)
+	}))
+	defer server.Close()
+
+	logger := testLogger{}
+	requests := make([]*RequestEvent, 0)
+	responses := make([]*ResponseEvent, 0)
+	client := New(server.URL, "admin", "secret",
+		WithServerVersion("16.7.0"),
+		WithLogger(&logger),
+		WithBeforeRequest(func(ctx context.Context, event *RequestEvent) context.Context {
+			requests = append(requests, event)
+			return context.WithValue(ctx, spanKey{}, "span")
+		}),
+		WithAfterResponse(func(ctx context.Context, event *ResponseEvent) {
+			assert.Equal(t, ctx.Value(spanKey{}), "span")
+			responses = append(responses, event)
+		}))
+
+	env := NewEnvironment()
+	env.Name = "TEST"
+	env.AddEnvironmentVariables(&EnvironmentVariable{Name: "PASS", Secure: true, Value: "plain"})
+	assert.NoError(t, client.NewEnvironment(env))
+
+	assert.Equal(t, len(requests), 1)
+	assert.Equal(t, requests[0].Method, "POST")
+	assert.Equal(t, requests[0].Attempt, 1)
+	assert.Equal(t, requests[0].Header.Get("Authorization"), "REDACTED")
+	assert.False(t, strings.Contains(string(requests[0].Body), "plain"))
+
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].StatusCode, http.StatusOK)
+	assert.False(t, strings.Contains(string(responses[0].Body), "xyz"))
+
+	assert.Equal(t, len(logger), 1)
+	assert.True(t, strings.HasPrefix(logger[0], fmt.Sprintf("gocd: POST %s/go/api/admin/environments: 200 (", server.URL)))
+}
+
+func TestRedactBody(t *testing.T) {
+	body := redactBody("application/json", []byte(`{"login_name":"jdoe","password":"secret","environment_variables":[
+		{"name":"A","secure":false,"value":"visible"},{"name":"B","secure":true,"value":"hidden"}]}`))
+	data := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(body, &data))
+	assert.Equal(t, data["password"], "REDACTED")
+	variables := data["environment_variables"].([]interface{})
+	assert.Equal(t, variables[0].(map[string]interface{})["value"], "visible")
+	assert.Equal(t, variables[1].(map[string]interface{})["value"], "REDACTED")
+
+	form := redactBody("application/x-www-form-urlencoded", []byte("variables[A]=1&secure_variables[B]=2"))
+	assert.Equal(t, string(form), "secure_variables%5BB%5D=REDACTED&variables%5BA%5D=1")
+
+	assert.Equal(t, string(redactBody("text/plain", []byte("password"))), "password")
+}
@@ -0,0 +1,113 @@
+package gocd
+
+import (
+	"context"
+	"encoding/xml"
+)
+
+type Pagination struct {
+	Offset   int `json:"offset"`
+	Total    int `json:"total"`
+	PageSize int `json:"page_size"`
+}
+
+// JobHistory is one page of jobs, newest first.
+type JobHistory struct {
+	Jobs       []*Job     `json:"jobs"`
+	Pagination Pagination `json:"pagination"`
+}
+
+func NewJobHistory() *JobHistory {
+	return &JobHistory{Jobs: make([]*Job, 0)}
+}
+
+// JobIterator walks every page of a job history:
+//
+//	it := client.IterateAgentJobHistory(uuid)
+//	for it.Next() {
+//		job := it.Job()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type JobIterator struct {
+	ctx    context.Context
+	fetch  func(ctx context.Context, offset int) (*JobHistory, error)
+	page   []*Job
+	offset int
+	total  int
+	job    *Job
+	err    error
+	done   bool
+}
+
+func newJobIterator(ctx context.Context, fetch func(ctx context.Context, offset int) (*JobHistory, error)) *JobIterator {
+	return &JobIterator{ctx: ctx, fetch: fetch}
+}
+
+// Next advances to the next job, fetching the next page when needed. It
+// returns false when the history is exhausted or an error occurred.
+func (p *JobIterator) Next() bool {
+	if p.err != nil {
+		return false
+	}
+	if len(p.page) == 0 {
+		if p.done {
+			return false
+		}
+		history, err := p.fetch(p.ctx, p.offset)
+		if err != nil {
+			p.err = err
+			return false
+		}
+		p.page, p.total = history.Jobs, history.Pagination.Total
+		p.offset += len(history.Jobs)
+		if len(history.Jobs) == 0 || p.offset >= p.total {
+			p.done = true
+		}
+		if len(p.page) == 0 {
+			return false
+		}
+	}
+	p.job, p.page = p.page[0], p.page[1:]
+	return true
+}
+
+func (p *JobIterator) Job() *Job {
+	return p.job
+}
+
+func (p *JobIterator) Err() error {
+	return p.err
+}
+
+// ScheduledJob is a job waiting for an agent, as listed by the
+// scheduled jobs feed.
+type ScheduledJob struct {
+	XMLName              xml.Name               `xml:"job"`
+	Name                 string                 `xml:"name,attr"`
+	ID                   int                    `xml:"id,attr"`
+	BuildLocator         string                 `xml:"buildLocator"`
+	Environment          string                 `xml:"environment"`
+	Resources            []string               `xml:"resources>resource"`
+	EnvironmentVariables []ScheduledJobVariable `xml:"environmentVariables>variable"`
+}
+
+type ScheduledJobVariable struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ScheduledJobsByResource groups jobs by the resources they need; jobs
+// without resources are listed under "".
+func ScheduledJobsByResource(jobs []*ScheduledJob) map[string][]*ScheduledJob {
+	result := make(map[string][]*ScheduledJob)
+	for _, job := range jobs {
+		if len(job.Resources) == 0 {
+			result[""] = append(result[""], job)
+		}
+		for _, resource := range job.Resources {
+			result[resource] = append(result[resource], job)
+		}
+	}
+	return result
+}
@@ -0,0 +1,12 @@
+package gocd
+
+// MaterialModifications is one page of modifications of a material, newest
+// first.
+type MaterialModifications struct {
+	Modifications []*Modification `json:"modifications"`
+	Pagination    Pagination      `json:"pagination"`
+}
+
+func NewMaterialModifications() *MaterialModifications {
+	return &MaterialModifications{Modifications: make([]*Modification, 0)}
+}
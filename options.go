@@ -0,0 +1,109 @@
+package gocd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures a Client created by New.
+type Option func(*Client)
+
+// WithHTTPClient makes the Client send its requests through client instead of
+// http.DefaultClient. The client is copied, so later options do not modify it.
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *Client) {
+		p.httpClient = client
+	}
+}
+
+// WithTransport replaces the RoundTripper of the underlying http.Client.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(p *Client) {
+		p.transport = transport
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to talk to the server.
+// TLS and proxy options are only applied to *http.Transport round trippers.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(p *Client) {
+		p.tlsConfig = config.Clone()
+	}
+}
+
+// WithRootCAs sets the certificate authorities used to verify the server.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(p *Client) {
+		p.tlsClientConfig().RootCAs = pool
+	}
+}
+
+// WithClientCertificates sets the certificates presented to the server.
+func WithClientCertificates(certs ...tls.Certificate) Option {
+	return func(p *Client) {
+		p.tlsClientConfig().Certificates = append(p.tlsClientConfig().Certificates, certs...)
+	}
+}
+
+// WithProxy sends every request through the proxy at proxyURL.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(p *Client) {
+		p.proxy = proxyURL
+	}
+}
+
+// WithTimeout limits the time of a single request, including reading the body.
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Client) {
+		p.timeout = timeout
+	}
+}
+
+// WithUserAgent overrides the default "go-gocd/VERSION" User-Agent header.
+func WithUserAgent(userAgent string) Option {
+	return func(p *Client) {
+		p.userAgent = userAgent
+	}
+}
+
+func (p *Client) tlsClientConfig() *tls.Config {
+	if p.tlsConfig == nil {
+		p.tlsConfig = &tls.Config{}
+	}
+	return p.tlsConfig
+}
+
+func (p *Client) buildHTTPClient() *http.Client {
+	base := http.DefaultClient
+	if p.httpClient != nil {
+		base = p.httpClient
+	}
+	client := *base
+
+	if p.transport != nil {
+		client.Transport = p.transport
+	}
+	if p.tlsConfig != nil || p.proxy != nil {
+		transport, ok := client.Transport.(*http.Transport)
+		if client.Transport == nil {
+			transport, ok = http.DefaultTransport.(*http.Transport)
+		}
+		if ok {
+			transport = transport.Clone()
+			if p.tlsConfig != nil {
+				transport.TLSClientConfig = p.tlsConfig
+			}
+			if p.proxy != nil {
+				transport.Proxy = http.ProxyURL(p.proxy)
+			}
+			client.Transport = transport
+		}
+	}
+	if p.timeout > 0 {
+		client.Timeout = p.timeout
+	}
+	return &client
+}
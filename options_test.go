@@ -0,0 +1,88 @@
+package gocd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestNew_UserAgent(t *testing.T) {
+	agents := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, New(server.URL, "", "", WithServerVersion("17.3.0")).UnpausePipeline("pipeline"))
+	assert.NoError(t, New(server.URL, "", "", WithUserAgent("bot/1.0"), WithServerVersion("17.3.0")).UnpausePipeline("pipeline"))
+	assert.Equal(t, agents, []string{fmt.Sprintf("go-gocd/%s", VERSION), "bot/1.0"})
+}
+
+func TestNew_WithTransport(t *testing.T) {
+	calls := 0
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithHTTPClient(&http.Client{}), WithTransport(transport), WithServerVersion("17.3.0"))
+	assert.NoError(t, client.UnpausePipeline("pipeline"))
+	assert.Equal(t, calls, 1)
+}
+
+func TestNew_WithRootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	assert.Error(t, New(server.URL, "", "", WithServerVersion("17.3.0")).UnpausePipeline("pipeline"))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	assert.NoError(t, New(server.URL, "", "", WithRootCAs(pool), WithServerVersion("17.3.0")).UnpausePipeline("pipeline"))
+}
+
+func TestNew_WithProxy(t *testing.T) {
+	proxied := make([]string, 0)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := New("http://gocd.example.com:8153", "", "", WithProxy(proxyURL), WithServerVersion("17.3.0"))
+	assert.NoError(t, client.UnpausePipeline("pipeline"))
+	assert.Equal(t, proxied, []string{"http://gocd.example.com:8153/go/api/pipelines/pipeline/unpause"})
+}
+
+func TestNew_WithTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithTimeout(50*time.Millisecond), WithServerVersion("17.3.0"))
+	assert.Error(t, client.UnpausePipeline("pipeline"))
+}
@@ -1,7 +1,10 @@
 package gocd
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"sort"
 
 	"github.com/fatih/structs"
 )
@@ -24,20 +27,38 @@ type Material struct {
 }
 
 type MaterialGitConfig struct {
-	Type       string `json:"type"`
-	Attributes struct {
-		Name        string `json:"name"`
-		URL         string `json:"url"`
-		Branch      string `json:"branch"`
-		Destination string `json:"destination"`
-		AutoUpdate  bool   `json:"auto_update"`
-		Filter      struct {
-			Ignore []string `json:"ignore"`
-		} `json:"filter"`
-		InvertFilter    bool   `json:"invert_filter"`
-		SubmoduleFolder string `json:"submodule_folder"`
-		ShallowClone    bool   `json:"shallow_clone"`
-	} `json:"attributes"`
+	Type       string             `json:"type"`
+	Attributes MaterialAttributes `json:"attributes"`
+}
+
+// MaterialAttributes are the settings of a git material; those of other
+// kinds of material, such as the pipeline and stage of a dependency, are kept
+// as they were read.
+type MaterialAttributes struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Branch      string `json:"branch"`
+	Destination string `json:"destination"`
+	AutoUpdate  bool   `json:"auto_update"`
+	Filter      struct {
+		Ignore []string `json:"ignore"`
+	} `json:"filter"`
+	InvertFilter    bool   `json:"invert_filter"`
+	SubmoduleFolder string `json:"submodule_folder"`
+	ShallowClone    bool   `json:"shallow_clone"`
+	extra           unknownFields
+}
+
+func (p *MaterialAttributes) UnmarshalJSON(data []byte) error {
+	type plain MaterialAttributes
+	extra, err := unmarshalKnown(data, (*plain)(p))
+	p.extra = extra
+	return err
+}
+
+func (p MaterialAttributes) MarshalJSON() ([]byte, error) {
+	type plain MaterialAttributes
+	return marshalKnown(plain(p), p.extra)
 }
 
 type Modification struct {
@@ -85,6 +106,21 @@ type JobConfig struct {
 	EnvironmentVariables []map[string]interface{} `json:"environment_variables"`
 	Resources            []string                 `json:"resources"`
 	Tasks                []map[string]interface{} `json:"tasks"`
+	extra                unknownFields
+}
+
+// UnmarshalJSON keeps the fields JobConfig does not model, such as artifacts,
+// tabs and elastic_profile_id, to send them back in MarshalJSON.
+func (p *JobConfig) UnmarshalJSON(data []byte) error {
+	type plain JobConfig
+	extra, err := unmarshalKnown(data, (*plain)(p))
+	p.extra = extra
+	return err
+}
+
+func (p JobConfig) MarshalJSON() ([]byte, error) {
+	type plain JobConfig
+	return marshalKnown(plain(p), p.extra)
 }
 
 func (p *JobConfig) AddTask(task interface{}) error {
@@ -160,6 +196,7 @@ type Stage struct {
 	RerunOfCounter        int    `json:"rerun_of_counter,omitempty"`
 	FetchMaterials        bool   `json:"fetch_materials,omitempty"`
 	ArtifactsDeleted      bool   `json:"artifacts_deleted,omitempty"`
+	Status                string `json:"status,omitempty"`
 }
 
 func NewStage() *Stage {
@@ -184,6 +221,19 @@ type StageConfig struct {
 	} `json:"approval"`
 	EnvironmentVariables []EnvironmentVariable `json:"environment_variables"`
 	Jobs                 []JobConfig           `json:"jobs"`
+	extra                unknownFields
+}
+
+func (p *StageConfig) UnmarshalJSON(data []byte) error {
+	type plain StageConfig
+	extra, err := unmarshalKnown(data, (*plain)(p))
+	p.extra = extra
+	return err
+}
+
+func (p StageConfig) MarshalJSON() ([]byte, error) {
+	type plain StageConfig
+	return marshalKnown(plain(p), p.extra)
 }
 
 type PipelineInstance struct {
@@ -195,6 +245,7 @@ type PipelineInstance struct {
 	Counter      int        `json:"counter,omitempty"`
 	ID           int        `json:"id,omitempty"`
 	Label        string     `json:"label,omitempty"`
+	TriggeredBy  string     `json:"triggered_by,omitempty"`
 	BuildCause   BuildCause `json:"build_cause,omitempty"`
 }
 
@@ -203,6 +254,7 @@ func NewPipelineInstance() *PipelineInstance {
 }
 
 type PipelineConfig struct {
+	Etag                  string                   `json:"-"`
 	LabelTemplate         string                   `json:"label_template,omitempty"`
 	EnablePipelineLocking bool                     `json:"enable_pipeline_locking"`
 	Name                  string                   `json:"name"`
@@ -211,6 +263,21 @@ type PipelineConfig struct {
 	EnvironmentVariables  []map[string]interface{} `json:"environment_variables"`
 	Materials             []MaterialGitConfig      `json:"materials"`
 	Stages                []StageConfig            `json:"stages"`
+	extra                 unknownFields
+}
+
+// UnmarshalJSON keeps the fields PipelineConfig does not model, such as the
+// timer and tracking_tool, to send them back in MarshalJSON.
+func (p *PipelineConfig) UnmarshalJSON(data []byte) error {
+	type plain PipelineConfig
+	extra, err := unmarshalKnown(data, (*plain)(p))
+	p.extra = extra
+	return err
+}
+
+func (p PipelineConfig) MarshalJSON() ([]byte, error) {
+	type plain PipelineConfig
+	return marshalKnown(plain(p), p.extra)
 }
 
 func NewPipelineConfig() *PipelineConfig {
@@ -221,3 +288,103 @@ func NewPipelineConfig() *PipelineConfig {
 		Materials:             make([]MaterialGitConfig, 0),
 		Stages:                make([]StageConfig, 0)}
 }
+
+type PipelineStatus struct {
+	Paused      bool   `json:"paused"`
+	PausedCause string `json:"pausedCause"`
+	PausedBy    string `json:"pausedBy"`
+	Locked      bool   `json:"locked"`
+	Schedulable bool   `json:"schedulable"`
+}
+
+// UnmarshalJSON also accepts the snake_case names newer servers use.
+func (p *PipelineStatus) UnmarshalJSON(data []byte) error {
+	type status PipelineStatus
+	result := struct {
+		status
+		PausedCause string `json:"paused_cause"`
+		PausedBy    string `json:"paused_by"`
+	}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	*p = PipelineStatus(result.status)
+	if p.PausedCause == "" {
+		p.PausedCause = result.PausedCause
+	}
+	if p.PausedBy == "" {
+		p.PausedBy = result.PausedBy
+	}
+	return nil
+}
+
+// ScheduleOptions overrides what a triggered pipeline runs with. Materials
+// maps material fingerprints to revisions. SkipMaterialUpdate schedules with
+// the revisions already known instead of polling the materials first.
+type ScheduleOptions struct {
+	Materials          map[string]string
+	Variables          map[string]string
+	SecureVariables    map[string]string
+	SkipMaterialUpdate bool
+}
+
+func NewScheduleOptions() *ScheduleOptions {
+	return &ScheduleOptions{
+		Materials:       make(map[string]string),
+		Variables:       make(map[string]string),
+		SecureVariables: make(map[string]string)}
+}
+
+// MarshalJSON encodes the options the way versioned schedule APIs expect.
+func (p *ScheduleOptions) MarshalJSON() ([]byte, error) {
+	type material struct {
+		Fingerprint string `json:"fingerprint"`
+		Revision    string `json:"revision"`
+	}
+	type variable struct {
+		Name   string `json:"name"`
+		Secure bool   `json:"secure"`
+		Value  string `json:"value"`
+	}
+	result := struct {
+		Materials            []material `json:"materials,omitempty"`
+		EnvironmentVariables []variable `json:"environment_variables,omitempty"`
+		UpdateMaterials      bool       `json:"update_materials_before_scheduling"`
+	}{UpdateMaterials: !p.SkipMaterialUpdate}
+
+	for _, fingerprint := range sortedKeys(p.Materials) {
+		result.Materials = append(result.Materials, material{fingerprint, p.Materials[fingerprint]})
+	}
+	for _, name := range sortedKeys(p.Variables) {
+		result.EnvironmentVariables = append(result.EnvironmentVariables, variable{name, false, p.Variables[name]})
+	}
+	for _, name := range sortedKeys(p.SecureVariables) {
+		result.EnvironmentVariables = append(result.EnvironmentVariables, variable{name, true, p.SecureVariables[name]})
+	}
+	return json.Marshal(result)
+}
+
+// form encodes the options for servers before 18.2.0, which always update
+// materials before scheduling.
+func (p *ScheduleOptions) form() []byte {
+	values := url.Values{}
+	for fingerprint, revision := range p.Materials {
+		values.Set(fmt.Sprintf("material_fingerprint[%s]", fingerprint), revision)
+	}
+	for name, value := range p.Variables {
+		values.Set(fmt.Sprintf("variables[%s]", name), value)
+	}
+	for name, value := range p.SecureVariables {
+		values.Set(fmt.Sprintf("secure_variables[%s]", name), value)
+	}
+	return []byte(values.Encode())
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
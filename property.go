@@ -0,0 +1,68 @@
+package gocd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// JobProperties are the properties of one run of a job, by name.
+type JobProperties map[string]string
+
+// Float parses the named property as a number, as recorded for metrics.
+func (p JobProperties) Float(name string) (float64, error) {
+	value, ok := p[name]
+	if !ok {
+		return 0, fmt.Errorf("Property %s is not set", name)
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// HistoricalJobProperties are the properties of one run of a job found by a
+// property search, along with the instance it belongs to.
+type HistoricalJobProperties struct {
+	PipelineLabel   string
+	PipelineCounter int
+	StageCounter    int
+	Properties      JobProperties
+}
+
+func propertiesURL(host, pipeline string, pCounter int, stage string, sCounter int, job string) string {
+	return fmt.Sprintf("%s/go/properties/%s/%d/%s/%d/%s", host,
+		url.PathEscape(pipeline), pCounter, url.PathEscape(stage), sCounter, url.PathEscape(job))
+}
+
+// parseProperties reads the CSV the properties API returns: a header row of
+// property names followed by one row per job run.
+func parseProperties(r io.Reader) ([]JobProperties, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]JobProperties, 0)
+	if len(records) == 0 {
+		return result, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		properties := make(JobProperties)
+		for i, value := range record {
+			if i < len(header) {
+				properties[header[i]] = value
+			}
+		}
+		result = append(result, properties)
+	}
+	return result, nil
+}
+
+func newHistoricalJobProperties(properties JobProperties) *HistoricalJobProperties {
+	result := &HistoricalJobProperties{
+		PipelineLabel: properties["cruise_pipeline_label"],
+		Properties:    properties}
+	result.PipelineCounter, _ = strconv.Atoi(properties["cruise_pipeline_counter"])
+	result.StageCounter, _ = strconv.Atoi(properties["cruise_stage_counter"])
+	return result
+}
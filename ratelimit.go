@@ -0,0 +1,118 @@
+package gocd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits the Client to perSecond requests per second on
+// average, allowing bursts of up to burst requests. Retries count as requests.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(p *Client) {
+		if perSecond > 0 {
+			p.limiter = newRateLimiter(perSecond, burst)
+		}
+	}
+}
+
+// WithMaxInFlight caps the number of requests whose response body has not yet
+// been closed.
+func WithMaxInFlight(n int) Option {
+	return func(p *Client) {
+		if n > 0 {
+			p.inFlight = make(chan struct{}, n)
+		}
+	}
+}
+
+// rateLimiter is a token bucket. Callers reserve a token, possibly driving
+// the bucket negative, and wait until their reservation is due.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (p *rateLimiter) reserve() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.tokens += now.Sub(p.last).Seconds() * p.rate
+	if p.tokens > p.burst {
+		p.tokens = p.burst
+	}
+	p.last = now
+
+	p.tokens--
+	if p.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-p.tokens / p.rate * float64(time.Second))
+}
+
+func (p *rateLimiter) cancel() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens++
+}
+
+func (p *rateLimiter) wait(ctx context.Context) error {
+	if delay := p.reserve(); delay > 0 {
+		if err := sleep(ctx, delay); err != nil {
+			p.cancel()
+			return err
+		}
+	}
+	return nil
+}
+
+// acquire waits for the rate limiter and a free in-flight slot. The returned
+// function gives the slot back.
+func (p *Client) acquire(ctx context.Context) (func(), error) {
+	if p.limiter != nil {
+		if err := p.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if p.inFlight == nil {
+		return func() {}, nil
+	}
+	select {
+	case p.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-p.inFlight })
+	}, nil
+}
+
+// releaseBody gives the in-flight slot back when the response body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (p *releaseBody) Close() error {
+	defer p.release()
+	return p.ReadCloser.Close()
+}
+
+func withRelease(resp *http.Response, release func()) *http.Response {
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp
+}
@@ -0,0 +1,73 @@
+package gocd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_MaxInFlight(t *testing.T) {
+	var mu sync.Mutex
+	current, max := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		current++
+		if current > max {
+			max = current
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		current--
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithMaxInFlight(2), WithServerVersion("17.3.0"))
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.UnpausePipeline("pipeline"))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, max, 2)
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithRateLimit(50, 1), WithServerVersion("17.3.0"))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, client.UnpausePipeline("pipeline"))
+	}
+	assert.True(t, time.Since(start) >= 75*time.Millisecond)
+}
+
+func TestClient_RateLimitContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithRateLimit(0.1, 1), WithServerVersion("17.3.0"))
+	assert.NoError(t, client.UnpausePipeline("pipeline"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := client.UnpausePipelineContext(ctx, "pipeline")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
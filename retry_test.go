@@ -0,0 +1,150 @@
+package gocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  5 * time.Millisecond,
+	RetryStatus: DefaultRetryPolicy.RetryStatus}
+
+func TestClient_RetryIdempotent(t *testing.T) {
+	bodies := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithRetryPolicy(testRetryPolicy), WithServerVersion("16.7.0"))
+	assert.NoError(t, client.SetPipelineConfigRaw("pipeline", []byte(`{"name":"pipeline"}`)))
+	assert.Equal(t, bodies, []string{`{"name":"pipeline"}`, `{"name":"pipeline"}`, `{"name":"pipeline"}`})
+}
+
+func TestClient_RetryExhausted(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithRetryPolicy(testRetryPolicy), WithServerVersion("16.7.0"))
+	_, err := client.GetPipelineConfig("pipeline")
+	assert.Equal(t, requests, 3)
+	assert.True(t, hasStatus(err, http.StatusBadGateway))
+}
+
+func TestClient_RetryPostStatus(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithRetryPolicy(testRetryPolicy), WithServerVersion("16.7.0"))
+	_, err := client.SchedulePipeline("pipeline", nil)
+	assert.Error(t, err)
+	assert.Equal(t, requests, 1)
+}
+
+func TestClient_RetryPostConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	dials := 0
+	transport := &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		if dials == 1 {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}}
+
+	client := New(server.URL, "", "", WithTransport(transport), WithRetryPolicy(testRetryPolicy), WithServerVersion("16.7.0"))
+	_, err := client.SchedulePipeline("pipeline", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, dials, 2)
+}
+
+func TestClient_RetryContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	policy := RetryPolicy{MaxAttempts: 3, RetryStatus: DefaultRetryPolicy.RetryStatus}
+	client := New(server.URL, "", "", WithRetryPolicy(policy))
+	_, err := client.GetPipelineConfigContext(ctx, "pipeline")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestClient_RetryAfterClamped(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", "", WithRetryPolicy(testRetryPolicy), WithServerVersion("16.7.0"))
+	start := time.Now()
+	assert.NoError(t, client.DeleteAgent("uuid"))
+	assert.Equal(t, requests, 2)
+	assert.True(t, time.Since(start) < time.Second, fmt.Sprint(time.Since(start)))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		delay := policy.backoff(attempt)
+		assert.True(t, delay >= max*time.Millisecond/2, fmt.Sprint(delay))
+		assert.True(t, delay <= max*time.Millisecond, fmt.Sprint(delay))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	_, ok := retryAfter(resp)
+	assert.False(t, ok)
+
+	resp.Header.Set("Retry-After", "7")
+	delay, ok := retryAfter(resp)
+	assert.True(t, ok)
+	assert.Equal(t, delay, 7*time.Second)
+
+	resp.Header.Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	delay, ok = retryAfter(resp)
+	assert.True(t, ok)
+	assert.Equal(t, delay, time.Duration(0))
+}
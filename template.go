@@ -0,0 +1,70 @@
+package gocd
+
+import (
+	"encoding/json"
+)
+
+// Template is an entry of the template listing: a template and the pipelines
+// built from it.
+type Template struct {
+	Name      string
+	Pipelines []string
+}
+
+type TemplateConfig struct {
+	Etag   string        `json:"-"`
+	Name   string        `json:"name"`
+	Stages []StageConfig `json:"stages"`
+	extra  unknownFields
+}
+
+func NewTemplateConfig() *TemplateConfig {
+	return &TemplateConfig{Stages: make([]StageConfig, 0)}
+}
+
+// UnmarshalJSON keeps the fields TemplateConfig does not model to send them
+// back in MarshalJSON; its stages and jobs keep theirs too.
+func (p *TemplateConfig) UnmarshalJSON(data []byte) error {
+	type plain TemplateConfig
+	extra, err := unmarshalKnown(data, (*plain)(p))
+	p.extra = extra
+	return err
+}
+
+func (p TemplateConfig) MarshalJSON() ([]byte, error) {
+	type plain TemplateConfig
+	return marshalKnown(plain(p), p.extra)
+}
+
+func (p *TemplateConfig) AddStage(stage StageConfig) {
+	p.Stages = append(p.Stages, stage)
+}
+
+// parseTemplates flattens the HAL _embedded documents of the template listing.
+func parseTemplates(data []byte) ([]*Template, error) {
+	templates := struct {
+		Embedded struct {
+			Templates []struct {
+				Name     string `json:"name"`
+				Embedded struct {
+					Pipelines []struct {
+						Name string `json:"name"`
+					} `json:"pipelines"`
+				} `json:"_embedded"`
+			} `json:"templates"`
+		} `json:"_embedded"`
+	}{}
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, err
+	}
+
+	result := make([]*Template, 0, len(templates.Embedded.Templates))
+	for _, t := range templates.Embedded.Templates {
+		template := &Template{Name: t.Name, Pipelines: make([]string, 0)}
+		for _, pipeline := range t.Embedded.Pipelines {
+			template.Pipelines = append(template.Pipelines, pipeline.Name)
+		}
+		result = append(result, template)
+	}
+	return result, nil
+}
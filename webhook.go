@@ -0,0 +1,149 @@
+package gocd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxWebhookBody caps the size of the payloads WebhookHandler reads.
+const maxWebhookBody = 5 << 20
+
+// WebhookHandler accepts push webhooks from GitHub, GitLab and Bitbucket and
+// notifies GoCD about every URL the pushed repository can be cloned from, so
+// pipelines with polling disabled are triggered. When Secret is set, GitHub
+// requests must carry a valid X-Hub-Signature-256, Bitbucket requests a valid
+// sha256 X-Hub-Signature and GitLab requests a matching X-Gitlab-Token.
+type WebhookHandler struct {
+	Client *Client
+	Secret string
+}
+
+func NewWebhookHandler(client *Client, secret string) *WebhookHandler {
+	return &WebhookHandler{Client: client, Secret: secret}
+}
+
+type webhookRepository struct {
+	CloneURL   string `json:"clone_url"`
+	SSHURL     string `json:"ssh_url"`
+	GitURL     string `json:"git_url"`
+	HTMLURL    string `json:"html_url"`
+	URL        string `json:"url"`
+	GitHTTPURL string `json:"git_http_url"`
+	GitSSHURL  string `json:"git_ssh_url"`
+	Links      struct {
+		HTML struct {
+			HRef string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+func (p *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	switch true {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case len(body) > maxWebhookBody:
+		http.Error(w, "webhook payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !p.authorized(r, body) {
+		http.Error(w, "invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get("X-GitHub-Event") == "ping" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	payload := struct {
+		Repository webhookRepository `json:"repository"`
+		Project    webhookRepository `json:"project"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	urls := webhookURLs(payload.Repository, payload.Project)
+	if len(urls) == 0 {
+		http.Error(w, "no repository url in payload", http.StatusBadRequest)
+		return
+	}
+
+	notified := make([]string, 0)
+	for _, repoURL := range urls {
+		err := p.Client.NotifyGitMaterialContext(r.Context(), repoURL)
+		switch true {
+		case err == nil:
+			notified = append(notified, repoURL)
+		case IsNotFound(err):
+			// no material uses this form of the url
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	if len(notified) == 0 {
+		http.Error(w, fmt.Sprintf("no material matches %s", strings.Join(urls, ", ")), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "notified %s\n", strings.Join(notified, ", "))
+}
+
+func (p *WebhookHandler) authorized(r *http.Request, body []byte) bool {
+	if p.Secret == "" {
+		return true
+	}
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(p.Secret)) == 1
+	}
+	// GitHub signs with X-Hub-Signature-256, Bitbucket with X-Hub-Signature
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		signature = r.Header.Get("X-Hub-Signature")
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func webhookURLs(repos ...webhookRepository) []string {
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, repo := range repos {
+		candidates := []string{repo.CloneURL, repo.SSHURL, repo.GitURL, repo.GitHTTPURL, repo.GitSSHURL, repo.HTMLURL}
+		if strings.HasPrefix(repo.URL, "http") || strings.HasPrefix(repo.URL, "git@") {
+			candidates = append(candidates, repo.URL)
+		}
+		if repo.Links.HTML.HRef != "" {
+			candidates = append(candidates, repo.Links.HTML.HRef, repo.Links.HTML.HRef+".git")
+		}
+		for _, candidate := range candidates {
+			if candidate != "" && !seen[candidate] {
+				seen[candidate] = true
+				result = append(result, candidate)
+			}
+		}
+	}
+	return result
+}
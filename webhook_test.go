@@ -0,0 +1,133 @@
+package gocd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const githubPush = `{"ref":"refs/heads/master","repository":{
+	"clone_url":"https://github.com/gocd/gocd.git",
+	"ssh_url":"git@github.com:gocd/gocd.git",
+	"html_url":"https://github.com/gocd/gocd"}}`
+
+func newNotifyServer(known string, notified *[]string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		repoURL := r.FormValue("repository_url")
+		if repoURL != known {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		*notified = append(*notified, repoURL)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler_GitHub(t *testing.T) {
+	notified := make([]string, 0)
+	server := newNotifyServer("git@github.com:gocd/gocd.git", &notified)
+	defer server.Close()
+
+	handler := NewWebhookHandler(New(server.URL, "", ""), "s3cr3t")
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(githubPush))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", sign("s3cr3t", githubPush))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusAccepted)
+	assert.Equal(t, notified, []string{"git@github.com:gocd/gocd.git"})
+
+	req = httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(githubPush))
+	req.Header.Set("X-Hub-Signature-256", sign("wrong", githubPush))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestWebhookHandler_GitLab(t *testing.T) {
+	notified := make([]string, 0)
+	server := newNotifyServer("https://gitlab.com/gocd/gocd.git", &notified)
+	defer server.Close()
+
+	handler := NewWebhookHandler(New(server.URL, "", ""), "token")
+	body := `{"object_kind":"push","project":{"git_http_url":"https://gitlab.com/gocd/gocd.git",
+		"git_ssh_url":"git@gitlab.com:gocd/gocd.git"}}`
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(body))
+	req.Header.Set("X-Gitlab-Token", "token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusAccepted)
+	assert.Equal(t, notified, []string{"https://gitlab.com/gocd/gocd.git"})
+}
+
+func TestWebhookHandler_NoMaterial(t *testing.T) {
+	notified := make([]string, 0)
+	server := newNotifyServer("https://github.com/gocd/other.git", &notified)
+	defer server.Close()
+
+	handler := NewWebhookHandler(New(server.URL, "", ""), "")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(githubPush)))
+	assert.Equal(t, w.Code, http.StatusNotFound)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(`{}`)))
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestWebhookHandler_Bitbucket(t *testing.T) {
+	notified := make([]string, 0)
+	server := newNotifyServer("https://bitbucket.org/gocd/gocd.git", &notified)
+	defer server.Close()
+
+	handler := NewWebhookHandler(New(server.URL, "", ""), "secret")
+	body := `{"push":{},"repository":{"links":{"html":{"href":"https://bitbucket.org/gocd/gocd"}}}}`
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(body))
+	req.Header.Set("X-Event-Key", "repo:push")
+	req.Header.Set("X-Hub-Signature", sign("secret", body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusAccepted)
+	assert.Equal(t, notified, []string{"https://bitbucket.org/gocd/gocd.git"})
+
+	req = httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(body))
+	req.Header.Set("X-Hub-Signature", sign("wrong", body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestWebhookHandler_TooLarge(t *testing.T) {
+	notified := make([]string, 0)
+	server := newNotifyServer("https://github.com/gocd/gocd.git", &notified)
+	defer server.Close()
+
+	handler := NewWebhookHandler(New(server.URL, "", ""), "")
+	body := bytes.NewBufferString(`{"padding":"`)
+	body.Write(bytes.Repeat([]byte("a"), maxWebhookBody))
+	body.WriteString(`"}`)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/webhook", body))
+	assert.Equal(t, w.Code, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, len(notified), 0)
+}